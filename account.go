@@ -0,0 +1,75 @@
+package argon2_go_withsecret
+
+import (
+	"errors"
+	"strings"
+)
+
+// adMarkerAccount is the ad= marker of encodings whose associated data is derived from an account identifier.
+const adMarkerAccount = "acct"
+
+var (
+	ErrAccountID       = errors.New("argon2-go-withsecret: account identifier is empty")
+	ErrNotAccountBound = errors.New("argon2-go-withsecret: encoded hash is not bound to an account")
+)
+
+// CanonicalAccountID returns the form of an account identifier that is bound into the hash.
+// Surrounding white space is removed and letters are lower cased so that "Alice " and "alice" bind the same.
+func CanonicalAccountID(accountID string) string {
+	return strings.ToLower(strings.TrimSpace(accountID))
+}
+
+// AccountAssociatedData returns the Argon2 associated data derived from an account identifier.
+func AccountAssociatedData(accountID string) []byte {
+	return []byte(adMarkerAccount + ":" + CanonicalAccountID(accountID))
+}
+
+// HashEncodedFor hashes password with a new salt from the Context salt generator and binds the hash to accountID
+// through the associated data. A password policy, if set, also rejects passwords containing the account ID.
+// The encoding records that an account is bound so that a hash copied onto another account's row fails to verify.
+// The Context associated data is restored afterwards.
+func (ctx *Context) HashEncodedFor(accountID string, password []byte) (string, error) {
+	if CanonicalAccountID(accountID) == "" {
+		return "", ErrAccountID
+	}
+	salt, err := ctx.newSalt()
+	if err != nil {
+		return "", err
+	}
+	defer ctx.bindAccount(accountID)()
+	return ctx.hashEncoded(password, salt, accountID)
+}
+
+// VerifyEncodedFor verifies an encoding produced by HashEncodedFor for the same account.
+// It returns ErrNotAccountBound if the encoding does not carry an account binding.
+// Like VerifyEncoded it mutates the Context to match the encoding, except for the associated data which is restored.
+func (ctx *Context) VerifyEncodedFor(accountID string, s string, password []byte) (bool, error) {
+	if CanonicalAccountID(accountID) == "" {
+		return false, ErrAccountID
+	}
+	defer ctx.bindAccount(accountID)()
+	defer ctx.restoreSecret()
+	hash, salt, err := ctx.decodeForVerify(s)
+	if err == nil && ctx.adMarker != adMarkerAccount {
+		err = ErrNotAccountBound
+	}
+	if err == nil {
+		password, err = ctx.preparePassword(password)
+	}
+	if err != nil {
+		ctx.observe(&Observation{Op: OpVerifyEncoded, Outcome: OutcomeError, Err: err})
+		return false, err
+	}
+	return ctx.verify(OpVerifyEncoded, hash, password, salt)
+}
+
+// bindAccount sets the associated data for accountID and returns a func restoring the previous state.
+func (ctx *Context) bindAccount(accountID string) func() {
+	ad, marker := ctx.AssociatedData, ctx.adMarker
+	ctx.SetAssociatedData(AccountAssociatedData(accountID))
+	ctx.adMarker = adMarkerAccount
+	return func() {
+		ctx.SetAssociatedData(ad)
+		ctx.adMarker = marker
+	}
+}
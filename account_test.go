@@ -0,0 +1,56 @@
+package argon2_go_withsecret
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashEncodedFor(t *testing.T) {
+	ctx := NewContext().SetMemory(1 << 10).SetSecret([]byte("somesecret"))
+	alice, err := ctx.HashEncodedFor("Alice", []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(alice, ",ad=") {
+		t.Errorf("account binding not flagged in %s", alice)
+	}
+	if ctx.AssociatedData != nil {
+		t.Errorf("associated data not restored: %q", ctx.AssociatedData)
+	}
+
+	ok, err := NewContext().SetSecret([]byte("somesecret")).VerifyEncodedFor(" alice", alice, []byte("password"))
+	if err != nil || !ok {
+		t.Errorf("VerifyEncodedFor(alice) = %v, %v  want true", ok, err)
+	}
+
+	// alice copies her hash onto the admin row
+	ok, err = NewContext().SetSecret([]byte("somesecret")).VerifyEncodedFor("admin", alice, []byte("password"))
+	if err != nil || ok {
+		t.Errorf("VerifyEncodedFor(admin) = %v, %v  want false", ok, err)
+	}
+
+	ok, err = NewContext().SetSecret([]byte("somesecret")).VerifyEncoded(alice, []byte("password"))
+	if err != ErrMissingAssociatedData || ok {
+		t.Errorf("VerifyEncoded without account = %v, %v  want false, %v", ok, err, ErrMissingAssociatedData)
+	}
+}
+
+func TestVerifyEncodedForUnbound(t *testing.T) {
+	ctx := NewContext().SetMemory(1 << 10)
+	s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewContext().VerifyEncodedFor("alice", s, []byte("password"))
+	if err != ErrNotAccountBound {
+		t.Errorf("got %v want %v", err, ErrNotAccountBound)
+	}
+	_, err = ctx.HashEncodedFor("  ", []byte("password"))
+	if err != ErrAccountID {
+		t.Errorf("got %v want %v", err, ErrAccountID)
+	}
+	_, _, err = ctx.SetFromEncoded("$argon2id$v=19$m=1024,t=3,p=2,foo=bar$c29tZXNhbHQ$aGFzaA")
+	if err != ErrEncodedFormatBadParameter {
+		t.Errorf("got %v want %v", err, ErrEncodedFormatBadParameter)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package argon2_go_withsecret
+
+// minorFaults is not available, so no faults/op are reported.
+func minorFaults() int64 {
+	return -1
+}
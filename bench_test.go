@@ -64,6 +64,20 @@ func BenchmarkHash_id_m21_p1(b *testing.B) { benchmarkHash(b, ModeArgon2id, 21,
 func BenchmarkHash_id_m21_p2(b *testing.B) { benchmarkHash(b, ModeArgon2id, 21, 2) }
 func BenchmarkHash_id_m21_p4(b *testing.B) { benchmarkHash(b, ModeArgon2id, 21, 4) }
 
+// The same with the memory pool, compare faults/op and MB/s with the benchmarks above.
+func BenchmarkHashPool_id_m16_p1(b *testing.B) { benchmarkHashPool(b, ModeArgon2id, 16, 1) } // 64 MiB
+func BenchmarkHashPool_id_m16_p2(b *testing.B) { benchmarkHashPool(b, ModeArgon2id, 16, 2) }
+func BenchmarkHashPool_id_m16_p4(b *testing.B) { benchmarkHashPool(b, ModeArgon2id, 16, 4) }
+func BenchmarkHashPool_id_m18_p1(b *testing.B) { benchmarkHashPool(b, ModeArgon2id, 18, 1) } // 256 MiB
+func BenchmarkHashPool_id_m18_p2(b *testing.B) { benchmarkHashPool(b, ModeArgon2id, 18, 2) }
+func BenchmarkHashPool_id_m18_p4(b *testing.B) { benchmarkHashPool(b, ModeArgon2id, 18, 4) }
+
+func benchmarkHashPool(b *testing.B, mode, memory, parallelism int) {
+	SetMemoryPool(true)
+	defer SetMemoryPool(false)
+	benchmarkHash(b, mode, memory, parallelism)
+}
+
 func benchmarkHash(b *testing.B, mode, memory, parallelism int) {
 	ctx := NewContext(mode)
 	ctx.SetMemory(1 << uint(memory))
@@ -71,9 +85,15 @@ func benchmarkHash(b *testing.B, mode, memory, parallelism int) {
 
 	b.SetBytes(int64(ctx.GetMemory()) << 10)
 
+	// without the memory pool libargon2 maps and unmaps its working memory for every hash,
+	// so each op faults in every page again
+	faults := minorFaults()
 	for n := 0; n < b.N; n++ {
 		if _, err := ctx.Hash(password, salt); err != nil {
 			b.Error(err)
 		}
 	}
+	if faults >= 0 {
+		b.ReportMetric(float64(minorFaults()-faults)/float64(b.N), "faults/op")
+	}
 }
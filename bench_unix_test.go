@@ -0,0 +1,14 @@
+//go:build unix
+
+package argon2_go_withsecret
+
+import "syscall"
+
+// minorFaults returns the minor page faults of the process so far.
+func minorFaults() int64 {
+	var ru syscall.Rusage
+	if syscall.Getrusage(syscall.RUSAGE_SELF, &ru) != nil {
+		return -1
+	}
+	return int64(ru.Minflt)
+}
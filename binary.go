@@ -0,0 +1,147 @@
+package argon2_go_withsecret
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+)
+
+// binaryVersion is the first byte of the binary encoding.
+const binaryVersion = 1
+
+// flags byte of the binary encoding
+const (
+	binaryNoVersion = 1 << iota // legacy encoding without v=
+	binaryKeyID                 // keyid= follows the hash
+	binaryData                  // ad= follows the hash and keyid
+	binaryNorm                  // norm= follows the hash, keyid and data
+	binaryPreHash               // pre=b2b, no field
+)
+
+var (
+	ErrBinaryFormat  = errors.New("argon2-go-withsecret: encoded hash has no binary form")
+	ErrBinaryCorrupt = errors.New("argon2-go-withsecret: cannot parse binary encoded hash")
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler with a compact, versioned form of the encoding:
+//
+//	version(1) mode(1) flags(1) uvarint(v) uvarint(m) uvarint(t) uvarint(p)
+//	uvarint(len) salt  uvarint(len) hash  [uvarint(len) keyid]  [uvarint(len) ad]  [uvarint(len) norm]
+//
+// A native encoding from HashEncoded, with or without hints, takes about half the space of the string
+// and converts back to exactly the same string. Wrapped legacy hashes and other libraries' formats give ErrBinaryFormat.
+func (ph PasswordHash) MarshalBinary() ([]byte, error) {
+	format, native := DecodeFormat(ph.encoded)
+	if ph.IsZero() || format != FormatNative {
+		return nil, ErrBinaryFormat
+	}
+	ctx := NewContext()
+	hash, salt, err := ctx.setFromEncoded(native)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.wrap != "" {
+		return nil, ErrBinaryFormat
+	}
+
+	var flags byte
+	if ctx.omitVersion {
+		flags |= binaryNoVersion
+	}
+	if ctx.keyID != nil {
+		flags |= binaryKeyID
+	}
+	if ctx.adMarker != "" {
+		flags |= binaryData
+	}
+	if ctx.normalization != "" {
+		flags |= binaryNorm
+	}
+	if ctx.preHash {
+		flags |= binaryPreHash
+	}
+	b := []byte{binaryVersion, byte(ctx.a2ctx.Mode), flags}
+	for _, v := range []int{ctx.a2ctx.Version, ctx.a2ctx.Memory, ctx.a2ctx.Iterations, ctx.a2ctx.Parallelism} {
+		b = binary.AppendUvarint(b, uint64(v))
+	}
+	b = appendBytes(b, salt)
+	b = appendBytes(b, hash)
+	if ctx.keyID != nil {
+		b = appendBytes(b, ctx.keyID)
+	}
+	if ctx.adMarker != "" {
+		b = appendBytes(b, []byte(ctx.adMarker))
+	}
+	if ctx.normalization != "" {
+		b = appendBytes(b, []byte(ctx.normalization))
+	}
+	// the binary form must convert back to the same string
+	var check PasswordHash
+	if check.UnmarshalBinary(b) != nil || check.encoded != native {
+		return nil, ErrBinaryFormat
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, validating like Scan.
+func (ph *PasswordHash) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 || data[0] != binaryVersion {
+		return ErrBinaryCorrupt
+	}
+	mode, flags := int(data[1]), data[2]
+	if mode > ModeArgon2id {
+		return ErrBinaryCorrupt
+	}
+	data = data[3:]
+
+	var params [4]int // v, m, t, p
+	for i := range params {
+		v, n := binary.Uvarint(data)
+		if n <= 0 || v > 1<<32 {
+			return ErrBinaryCorrupt
+		}
+		params[i], data = int(v), data[n:]
+	}
+	want := 2 // salt, hash, then keyid, data and norm if flagged
+	for _, flag := range []byte{binaryKeyID, binaryData, binaryNorm} {
+		if flags&flag != 0 {
+			want++
+		}
+	}
+	var fields [][]byte
+	for len(fields) < want {
+		l, n := binary.Uvarint(data)
+		if n <= 0 || l > uint64(len(data)-n) {
+			return ErrBinaryCorrupt
+		}
+		fields, data = append(fields, data[n:n+int(l)]), data[n+int(l):]
+	}
+	if len(data) != 0 {
+		return ErrBinaryCorrupt
+	}
+
+	ctx := NewContext(mode)
+	ctx.SetVersion(params[0]).SetMemory(params[1]).SetIterations(params[2]).SetParallelism(params[3])
+	ctx.omitVersion = flags&binaryNoVersion != 0
+	var options string
+	rest := fields[2:]
+	if flags&binaryKeyID != 0 {
+		options += ",keyid=" + base64.RawStdEncoding.EncodeToString(rest[0])
+		rest = rest[1:]
+	}
+	if flags&binaryData != 0 {
+		options += ",ad=" + base64.RawStdEncoding.EncodeToString(rest[0])
+		rest = rest[1:]
+	}
+	if flags&binaryNorm != 0 {
+		options += ",norm=" + string(rest[0])
+	}
+	if flags&binaryPreHash != 0 {
+		options += ",pre=" + preHashBLAKE2b
+	}
+	return ph.parse(ctx.encodeAs(argon2_type2string(mode), options, fields[0], fields[1]))
+}
+
+func appendBytes(b []byte, v []byte) []byte {
+	return append(binary.AppendUvarint(b, uint64(len(v))), v...)
+}
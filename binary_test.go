@@ -0,0 +1,58 @@
+package argon2_go_withsecret
+
+import (
+	"encoding"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = PasswordHash{}
+	_ encoding.BinaryUnmarshaler = &PasswordHash{}
+)
+
+func TestPasswordHashBinary(t *testing.T) {
+	hinted, err := NewContext().SetMemory(1<<10).SetSecret([]byte("somesecret")).SetEncodeHints(true).
+		SetAssociatedData([]byte("somedata")).HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		refArgon2id,
+		refArgon2i,
+		hinted,
+		"$argon2d$m=65536,t=3,p=2$c29tZXNhbHQ$CykrV8U+ZdXKv/r9fxmofesmRD/pWZRyvZvn+TgucPQ",
+	} {
+		ph, err := ParsePasswordHash(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ph.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if len(b) > len(s)*2/3 {
+			t.Errorf("%s: binary form is %d bytes", s, len(b))
+		}
+		var back PasswordHash
+		if err = back.UnmarshalBinary(b); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if back.String() != s || back.Params != ph.Params {
+			t.Errorf("round trip: got %q  want %q", back.String(), s)
+		}
+	}
+
+	ph, _ := ParsePasswordHash("{ARGON2}" + refArgon2id)
+	if _, err = ph.MarshalBinary(); err != ErrBinaryFormat {
+		t.Errorf("prefixed: got %v  want %v", err, ErrBinaryFormat)
+	}
+
+	ph, _ = ParsePasswordHash(refArgon2id)
+	b, _ := ph.MarshalBinary()
+	for _, corrupt := range [][]byte{nil, {2}, b[:len(b)-1], append(b, 0), append([]byte{1, 7}, b[2:]...)} {
+		var back PasswordHash
+		if err = back.UnmarshalBinary(corrupt); err != ErrBinaryCorrupt {
+			t.Errorf("%x: got %v  want %v", corrupt, err, ErrBinaryCorrupt)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package argon2_go_withsecret
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// bloomMagic starts a Bloom filter file, followed by a version byte.
+const bloomMagic = "A2BF"
+
+const bloomVersion = 1
+
+var ErrBloomFilter = errors.New("argon2-go-withsecret: malformed Bloom filter")
+
+// BloomFilter is a compact set of breached password hashes built from a BreachCorpus by BuildBloomFilter
+// or the argon2 breach-build command. It never misses a breached password and wrongly reports other passwords
+// at the false positive rate it was built for; 0.001 takes about 1.8 bytes per hash.
+type BloomFilter struct {
+	k    int      // bits set per hash
+	bits []uint64 // m bits
+}
+
+// NewBloomFilter returns an empty filter sized for n hashes at false positive rate fpRate.
+func NewBloomFilter(n int, fpRate float64) *BloomFilter {
+	n = max(n, 1)
+	m := math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(n) * math.Ln2))
+	return &BloomFilter{k: max(k, 1), bits: make([]uint64, (int(m)+63)/64)}
+}
+
+// BuildBloomFilter builds a filter of the hashes of a corpus, reading it twice.
+func BuildBloomFilter(c *BreachCorpus, fpRate float64) (*BloomFilter, error) {
+	n := 0
+	err := c.Each(func([sha1.Size]byte, int) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	bf := NewBloomFilter(n, fpRate)
+	err = c.Each(func(sum [sha1.Size]byte, _ int) error {
+		bf.Add(sum)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bf, nil
+}
+
+// positions calls fn with the k bit positions of a SHA-1, derived from two 64 bit halves of it.
+func (bf *BloomFilter) positions(sum [sha1.Size]byte, fn func(pos uint64)) {
+	m := uint64(len(bf.bits)) * 64
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	for i := 0; i < bf.k; i++ {
+		fn((h1 + uint64(i)*h2) % m)
+	}
+}
+
+// Add adds the SHA-1 of a password.
+func (bf *BloomFilter) Add(sum [sha1.Size]byte) {
+	bf.positions(sum, func(pos uint64) {
+		bf.bits[pos/64] |= 1 << (pos % 64)
+	})
+}
+
+// Contains reports whether the SHA-1 of a password may have been added.
+func (bf *BloomFilter) Contains(sum [sha1.Size]byte) bool {
+	found := true
+	bf.positions(sum, func(pos uint64) {
+		found = found && bf.bits[pos/64]&(1<<(pos%64)) != 0
+	})
+	return found
+}
+
+// Breached implements BreachChecker.
+func (bf *BloomFilter) Breached(password []byte) (bool, error) {
+	return bf.Contains(sha1.Sum(password)), nil
+}
+
+// WriteTo writes the filter as the magic, version, k, the number of 64 bit words and the words, little endian.
+func (bf *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := append([]byte(bloomMagic), bloomVersion, byte(bf.k))
+	header = binary.LittleEndian.AppendUint64(header, uint64(len(bf.bits)))
+	bw.Write(header)
+	var word [8]byte
+	for _, v := range bf.bits {
+		binary.LittleEndian.PutUint64(word[:], v)
+		bw.Write(word[:])
+	}
+	return int64(len(header) + 8*len(bf.bits)), bw.Flush()
+}
+
+// ReadBloomFilter reads a filter written by WriteTo.
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(bloomMagic)+10)
+	_, err := io.ReadFull(br, header)
+	if err != nil || string(header[:len(bloomMagic)]) != bloomMagic || header[4] != bloomVersion || header[5] == 0 {
+		return nil, ErrBloomFilter
+	}
+	words := binary.LittleEndian.Uint64(header[6:])
+	if words == 0 || words > 1<<40 {
+		return nil, ErrBloomFilter
+	}
+	bf := &BloomFilter{k: int(header[5]), bits: make([]uint64, words)}
+	var word [8]byte
+	for i := range bf.bits {
+		_, err = io.ReadFull(br, word[:])
+		if err != nil {
+			return nil, ErrBloomFilter
+		}
+		bf.bits[i] = binary.LittleEndian.Uint64(word[:])
+	}
+	return bf, nil
+}
+
+// LoadBloomFilter reads a filter file written by WriteTo.
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBloomFilter(f)
+}
@@ -0,0 +1,224 @@
+package argon2_go_withsecret
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hibpPrefixLen is the number of hex digits of the SHA-1 that name a range file of the HIBP k-anonymity API.
+const hibpPrefixLen = 5
+
+var ErrBreachCorpus = errors.New("argon2-go-withsecret: malformed breach corpus")
+
+// BreachChecker reports whether a password appears in a corpus of breached passwords.
+type BreachChecker interface {
+	Breached(password []byte) (bool, error)
+}
+
+// BreachCorpus is a local copy of the Have I Been Pwned SHA-1 Pwned Passwords, checked without calling any service.
+// It is either a directory of range files named by 5 hex digit prefix, each holding SUFFIX:COUNT lines as served by
+// the k-anonymity API, or a single file of HASH:COUNT lines ordered by hash, as distributed for download.
+// A missing range file is an error, so an incomplete download is noticed rather than passing every password.
+type BreachCorpus struct {
+	path     string
+	dir      bool
+	MinCount int // passwords seen fewer times are not reported as breached
+}
+
+// OpenBreachCorpus opens a range file directory or an ordered hash file.
+func OpenBreachCorpus(path string) (*BreachCorpus, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &BreachCorpus{path: path, dir: fi.IsDir(), MinCount: 1}, nil
+}
+
+// Breached reports whether the SHA-1 of password is in the corpus at least MinCount times.
+func (c *BreachCorpus) Breached(password []byte) (bool, error) {
+	sum := sha1.Sum(password)
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+	var count int
+	var err error
+	if c.dir {
+		count, err = c.searchRange(target)
+	} else {
+		count, err = c.searchFile(target)
+	}
+	return count > 0 && count >= c.MinCount, err
+}
+
+// searchRange scans the range file of the prefix of target.
+func (c *BreachCorpus) searchRange(target string) (int, error) {
+	prefix, suffix := target[:hibpPrefixLen], target[hibpPrefixLen:]
+	f, err := os.Open(filepath.Join(c.path, prefix))
+	if os.IsNotExist(err) {
+		f, err = os.Open(filepath.Join(c.path, prefix+".txt"))
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		hash, count, err := parseBreachLine(sc.Text())
+		if err != nil {
+			return 0, err
+		}
+		if hash == suffix {
+			return count, nil
+		}
+	}
+	return 0, sc.Err()
+}
+
+// searchFile binary searches the ordered hash file for target without reading it all.
+func (c *BreachCorpus) searchFile(target string) (int, error) {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// look for a line starting in [lo, hi)
+	lo, hi := int64(0), fi.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := lineAtOrAfter(f, mid)
+		if err != nil {
+			return 0, err
+		}
+		if line == "" || start >= hi {
+			hi = mid
+			continue
+		}
+		hash, count, err := parseBreachLine(line)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case hash == target:
+			return count, nil
+		case hash < target:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, nil
+}
+
+// lineAtOrAfter returns the first line of f starting at or after offset, without its line end, and its offset.
+// The line is empty at the end of the file.
+func lineAtOrAfter(f *os.File, offset int64) (int64, string, error) {
+	start := offset
+	if offset > 0 {
+		// the line starts at offset if the byte before it ends a line
+		start = offset - 1
+	}
+	_, err := f.Seek(start, io.SeekStart)
+	if err != nil {
+		return 0, "", err
+	}
+	r := bufio.NewReaderSize(f, 256)
+	if offset > 0 {
+		skipped, err := r.ReadString('\n')
+		if err == io.EOF {
+			return 0, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		start += int64(len(skipped))
+	}
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	return start, strings.TrimSuffix(line, "\n"), nil
+}
+
+// parseBreachLine splits a HASH:COUNT or SUFFIX:COUNT line, which may end in \r.
+func parseBreachLine(line string) (string, int, error) {
+	hash, count, found := strings.Cut(strings.TrimRight(line, "\r"), ":")
+	if !found {
+		return "", 0, fmt.Errorf("%w: %q", ErrBreachCorpus, line)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %q", ErrBreachCorpus, line)
+	}
+	return strings.ToUpper(hash), n, nil
+}
+
+// Each calls fn with the SHA-1 and count of every hash in the corpus seen at least MinCount times, in order.
+func (c *BreachCorpus) Each(fn func(sum [sha1.Size]byte, count int) error) error {
+	if !c.dir {
+		f, err := os.Open(c.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return c.each(f, "", fn)
+	}
+	entries, err := os.ReadDir(c.path)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if prefix := strings.TrimSuffix(e.Name(), ".txt"); len(prefix) == hibpPrefixLen && !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f, err := os.Open(filepath.Join(c.path, name))
+		if err != nil {
+			return err
+		}
+		err = c.each(f, strings.TrimSuffix(name, ".txt"), fn)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *BreachCorpus) each(r io.Reader, prefix string, fn func(sum [sha1.Size]byte, count int) error) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if sc.Text() == "" {
+			continue
+		}
+		hash, count, err := parseBreachLine(sc.Text())
+		if err != nil {
+			return err
+		}
+		var sum [sha1.Size]byte
+		n, err := hex.Decode(sum[:], []byte(prefix+hash))
+		if err != nil || n != sha1.Size {
+			return fmt.Errorf("%w: %q", ErrBreachCorpus, sc.Text())
+		}
+		if count >= c.MinCount {
+			err = fn(sum, count)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return sc.Err()
+}
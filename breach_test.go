@@ -0,0 +1,134 @@
+package argon2_go_withsecret
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var breachedPasswords = map[string]int{"password": 9545824, "123456": 37359195, "hunter2": 17, "rare-one": 1}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeBreachCorpora writes the passwords, with filler hashes around them, as an ordered file and as range files.
+func writeBreachCorpora(t *testing.T) (file string, dir string) {
+	lines := []string{}
+	for pw, count := range breachedPasswords {
+		lines = append(lines, fmt.Sprintf("%s:%d", sha1Hex(pw), count))
+	}
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("%s:%d", sha1Hex(fmt.Sprint("filler", i)), i+1))
+	}
+	sort.Strings(lines)
+
+	root := t.TempDir()
+	file = filepath.Join(root, "pwned-passwords-sha1-ordered-by-hash.txt")
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir = filepath.Join(root, "ranges")
+	os.Mkdir(dir, 0755)
+	ranges := map[string][]string{}
+	for _, line := range lines {
+		ranges[line[:5]] = append(ranges[line[:5]], line[5:])
+	}
+	for prefix, suffixes := range ranges {
+		if err := os.WriteFile(filepath.Join(dir, prefix), []byte(strings.Join(suffixes, "\r\n")), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return file, dir
+}
+
+func TestBreachCorpus(t *testing.T) {
+	file, dir := writeBreachCorpora(t)
+	for _, path := range []string{file, dir} {
+		c, err := OpenBreachCorpus(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for pw := range breachedPasswords {
+			if breached, err := c.Breached([]byte(pw)); !breached || err != nil {
+				t.Errorf("%s %q: got %v, %v  want true", filepath.Base(path), pw, breached, err)
+			}
+		}
+		for i := 0; i < 200; i += 37 {
+			if breached, _ := c.Breached([]byte(fmt.Sprint("filler", i))); !breached {
+				t.Errorf("%s filler%d: not found", filepath.Base(path), i)
+			}
+		}
+		breached, err := c.Breached([]byte("correct horse battery staple"))
+		if breached || (err != nil && !errors.Is(err, os.ErrNotExist)) {
+			t.Errorf("%s: unbreached password got %v, %v", filepath.Base(path), breached, err)
+		}
+		c.MinCount = 2
+		if breached, _ := c.Breached([]byte("rare-one")); breached {
+			t.Errorf("%s: count 1 reported with MinCount 2", filepath.Base(path))
+		}
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	_, dir := writeBreachCorpora(t)
+	c, _ := OpenBreachCorpus(dir)
+	bf, err := BuildBloomFilter(c, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "pwned.bloom")
+	f, _ := os.Create(path)
+	if _, err = bf.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	bf, err = LoadBloomFilter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pw := range breachedPasswords {
+		if breached, _ := bf.Breached([]byte(pw)); !breached {
+			t.Errorf("%q: not found", pw)
+		}
+	}
+	fp := 0
+	for i := 0; i < 10000; i++ {
+		if bf.Contains(sha1.Sum([]byte(fmt.Sprint("unbreached", i)))) {
+			fp++
+		}
+	}
+	if fp > 300 {
+		t.Errorf("%d false positives in 10000 at rate 0.01", fp)
+	}
+
+	if _, err = ReadBloomFilter(strings.NewReader("A2BF\x01")); err != ErrBloomFilter {
+		t.Errorf("truncated: got %v", err)
+	}
+}
+
+func TestPasswordPolicyBreaches(t *testing.T) {
+	file, _ := writeBreachCorpora(t)
+	c, _ := OpenBreachCorpus(file)
+	pp := NewPasswordPolicy()
+	pp.Breaches = c
+	if v := pp.CheckPassword([]byte("hunter2hunter2")); v != nil {
+		t.Errorf("unbreached: got %v", v)
+	}
+	pp.MinRunes = 6
+	if v := pp.CheckPassword([]byte("hunter2")); len(v) != 1 || v[0].Rule != RuleBreached {
+		t.Errorf("hunter2: got %v", v)
+	}
+
+	os.Remove(file)
+	if v := pp.CheckPassword([]byte("hunter2hunter2")); len(v) != 1 || v[0].Rule != RuleBreached {
+		t.Errorf("missing corpus: got %v  want fail closed", v)
+	}
+}
@@ -0,0 +1,156 @@
+// Command argon2 is a tool for reviewing argon2_go_withsecret parameters.
+//
+// Usage:
+//
+//	argon2 cost [-profile name | -m KiB -t iterations -p lanes] [-secret-bits n] [-candidates n] [-hardware file.json]
+//	argon2 breach-build [-fp rate] [-min-count n] -o filter corpus
+//
+// cost prints the estimated attacker cost of the parameters on each hardware profile, see CostModel.
+//
+// breach-build builds a BloomFilter from a local copy of the Pwned Passwords, see BreachCorpus.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/learnfromgirls/argon2-go-withsecret"
+)
+
+// commands maps subcommand names to their implementations.
+var commands = map[string]func(args []string, w io.Writer) error{
+	"cost":         cost,
+	"breach-build": breachBuild,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || commands[args[0]] == nil {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(stderr, "usage: argon2 <command> [flags]\ncommands: %v\n", names)
+		return 2
+	}
+	err := commands[args[0]](args[1:], stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "argon2 %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func cost(args []string, w io.Writer) error {
+	defaults := argon2_go_withsecret.NewContext().GetParams()
+	fs := flag.NewFlagSet("cost", flag.ContinueOnError)
+	profile := fs.String("profile", "", "named profile, instead of -m, -t and -p")
+	memory := fs.Int("m", defaults.Memory, "memory in KiB")
+	iterations := fs.Int("t", defaults.Iterations, "iterations")
+	lanes := fs.Int("p", defaults.Parallelism, "lanes")
+	secretBits := fs.Int("secret-bits", 0, "random bits of a secret the attacker does not have")
+	candidates := fs.Float64("candidates", 1e9, "equally likely passwords the attacker must search")
+	hardware := fs.String("hardware", "", "JSON file with an array of hardware profiles")
+	fs.SetOutput(w)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	params := argon2_go_withsecret.Params{Mode: argon2_go_withsecret.ModeArgon2id, Version: defaults.Version,
+		Memory: *memory, Iterations: *iterations, Parallelism: *lanes, HashLen: defaults.HashLen}
+	if *profile != "" {
+		p, found := argon2_go_withsecret.LookupProfile(*profile)
+		if !found {
+			return fmt.Errorf("unknown profile %q, have %v", *profile, argon2_go_withsecret.ProfileNames())
+		}
+		params = p.Params
+	}
+	cm := argon2_go_withsecret.NewCostModel()
+	if *hardware != "" {
+		data, err := os.ReadFile(*hardware)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(data, &cm.Hardware)
+		if err != nil {
+			return fmt.Errorf("%s: %v", *hardware, err)
+		}
+	}
+
+	fmt.Fprintf(w, "parameters: m=%d t=%d p=%d, searching %g candidates\n\n",
+		params.Memory, params.Iterations, params.Parallelism, *candidates)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "hardware\tin flight\tbound\tguesses/s\t$/guess\t$ to search")
+	if *secretBits > 0 {
+		fmt.Fprintf(tw, "\t$ with %d bit secret", *secretBits)
+	}
+	fmt.Fprintln(tw)
+	for _, e := range cm.Estimate(params) {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%.4g\t%.3g\t%s", e.Hardware.Name, e.Parallel, e.Bound,
+			e.GuessesPerSecond, e.DollarsPerGuess, dollars(e.SearchCost(*candidates, 0)))
+		if *secretBits > 0 {
+			fmt.Fprintf(tw, "\t%s", dollars(e.SearchCost(*candidates, *secretBits)))
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	if *secretBits == 0 {
+		fmt.Fprintln(w, "\nwithout a secret, or once it is stolen, only the parameters above protect the hashes")
+	}
+	return nil
+}
+
+func dollars(d float64) string {
+	if math.IsInf(d, 1) {
+		return "cannot run"
+	}
+	return fmt.Sprintf("%.3g", d)
+}
+
+func breachBuild(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("breach-build", flag.ContinueOnError)
+	fpRate := fs.Float64("fp", 0.001, "false positive rate")
+	minCount := fs.Int("min-count", 1, "leave out hashes seen fewer times")
+	out := fs.String("o", "", "filter file to write")
+	fs.SetOutput(w)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *out == "" || *fpRate <= 0 || *fpRate >= 1 {
+		return fmt.Errorf("need -o, one corpus and 0 < -fp < 1")
+	}
+	corpus, err := argon2_go_withsecret.OpenBreachCorpus(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	corpus.MinCount = *minCount
+	bf, err := argon2_go_withsecret.BuildBloomFilter(corpus, *fpRate)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	n, err := bf.WriteTo(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "wrote %s, %d bytes, false positive rate %g\n", *out, n, *fpRate)
+	return nil
+}
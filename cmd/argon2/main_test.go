@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/learnfromgirls/argon2-go-withsecret"
+)
+
+func TestCost(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"cost", "-profile", "owasp", "-secret-bits", "128"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"m=19456 t=2 p=1", "aws-g3.16xlarge", "$ with 128 bit secret"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+
+	hardware := filepath.Join(t.TempDir(), "hw.json")
+	os.WriteFile(hardware, []byte(`[{"name": "tiny", "cores": 1, "core_rate": 1e9, "memory": 1048576,
+		"bandwidth": 1e9, "price_per_hour": 1}]`), 0600)
+	stdout.Reset()
+	code = run([]string{"cost", "-hardware", hardware}, &stdout, &stderr)
+	if code != 0 || !strings.Contains(stdout.String(), "cannot run") {
+		t.Errorf("exit %d: %s", code, stdout.String())
+	}
+
+	if run([]string{"nope"}, &stdout, &stderr) != 2 {
+		t.Error("unknown command accepted")
+	}
+}
+
+func TestBreachBuild(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "corpus.txt")
+	// SHA-1 of "password"
+	os.WriteFile(corpus, []byte("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n"), 0644)
+	out := filepath.Join(dir, "pwned.bloom")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"breach-build", "-fp", "0.0001", "-o", out, corpus}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	bf, err := argon2_go_withsecret.LoadBloomFilter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if breached, _ := bf.Breached([]byte("password")); !breached {
+		t.Error("password not in filter")
+	}
+}
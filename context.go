@@ -7,8 +7,8 @@ import (
 	"github.com/tvdburgt/go-argon2"
 	"strings"
 	"sync"
-	"crypto/rand"
 	"github.com/learnfromgirls/safesecrets"
+	"log/slog"
 )
 
 var mutex = &sync.Mutex{}
@@ -94,17 +94,19 @@ var (
 
 var (
 	ErrEncodedFormat = errors.New("argon2-go-withsecret: cannot parse encodedhash")
-	ErrEncodedFormatNotSixParts = errors.New("argon2-go-withsecret: cannot parse encodedhash. Not 6 parts")
+	ErrEncodedFormatNotSixParts = errors.New("argon2-go-withsecret: cannot parse encodedhash. Not 5 or 6 parts")
 	ErrEncodedFormatUnknownType = errors.New("argon2-go-withsecret: cannot parse encodedhash. Unknown Type")
 	ErrEncodedFormatNoV = errors.New("argon2-go-withsecret: cannot parse encodedhash. No V")
 	ErrEncodedFormatNoM = errors.New("argon2-go-withsecret: cannot parse encodedhash. No M")
 	ErrEncodedFormatNoP = errors.New("argon2-go-withsecret: cannot parse encodedhash. No P")
 	ErrEncodedFormatNoT = errors.New("argon2-go-withsecret: cannot parse encodedhash. No T")
 	ErrEncodedFormatNotThreeSubParts = errors.New("argon2-go-withsecret: cannot parse encodedhash. Not 3 subparts")
+	ErrEncodedFormatBadParameter = errors.New("argon2-go-withsecret: cannot parse encodedhash. Unknown or malformed parameter")
 	ErrContext = errors.New("argon2: context is nil")
 	ErrPassword = errors.New("argon2: password is nil or empty")
 	ErrSalt = errors.New("argon2: salt is nil or empty")
 	ErrHash = errors.New("argon2: hash is nil or empty")
+	ErrVerifyLimit = errors.New("argon2-go-withsecret: encoded hash parameters exceed verify limits")
 )
 
 type A2Context argon2.Context
@@ -114,6 +116,32 @@ type Context struct {
 	AssociatedData []byte // used to populate a2ctx
 	Flags          int    //used to populate a2ctx
 	a2ctx          *argon2.Context
+	observer       Observer     // see SetObserver
+	logger         *slog.Logger // see SetLogger
+	adMarker       string       // written as ad= in the encoding when AssociatedData is in use
+	encodeHints    bool         // see SetEncodeHints
+	keyID          []byte       // secret fingerprint read from keyid= of the last encoding
+	omitVersion    bool         // the last encoding had no v= part, see UpgradeEncoding
+	wrap           string       // kind of legacy hash wrapped by the last encoding, see WrapLegacy
+	wrapSetting    []byte       // legacy salt and cost read from wrap= of the last encoding
+	keyRing        *KeyRing     // see SetKeyRing
+	retired        bool         // the last encoding was verified with a retired secret of the key ring
+	verifyLimit    Params       // see SetVerifyLimits
+	saltGenerator  *SaltGenerator // see SetSaltGenerator
+	passwordPolicy *PasswordPolicy // see SetPasswordPolicy
+	normalization  string          // written as norm= in the encoding, see SetNormalization
+	preHash        bool            // written as pre= in the encoding, see SetPreHash
+	threads        int             // see SetThreads, 0 for one per lane
+}
+
+// Params holds the Argon2 cost parameters of a Context.
+type Params struct {
+	Mode        int
+	Version     int
+	Memory      int // KiB
+	Iterations  int
+	Parallelism int
+	HashLen     int
 }
 
 // NewContext initializes a new Argon2 context with reasonable defaults for sub-second hashing time.
@@ -164,16 +192,10 @@ func NewVaultContext(mode ...int) *Context {
 
 
 
+// NewRandomSalt returns DefaultSaltLen random bytes from crypto/rand.
+// Argon2 accepts salts of 8 bytes or more; use a SaltGenerator for other lengths or sources.
 func NewRandomSalt() ([] byte, error) {
-
-	salt := make([]byte, 16) //argon salt length is fixed at 16
-
-	_, err := rand.Read(salt)
-	if err == nil {
-		return salt, nil
-	} else {
-		return nil, err
-	}
+	return (&SaltGenerator{}).NewSalt()
 }
 
 func argon2_type2string(a2t int) string {
@@ -253,6 +275,15 @@ func (ctx *Context) GetVersion() int {
 	return ctx.a2ctx.Version
 }
 
+// UpgradeEncoding moves a Context set from a legacy encoding without v= to the current version,
+// so that the next HashEncoded writes a versioned encoding.
+// Without it re-encoding preserves the legacy form.
+func (ctx *Context) UpgradeEncoding() *Context {
+	ctx.a2ctx.Version = VersionDefault
+	ctx.omitVersion = false
+	return ctx
+}
+
 
 // sets Context fields from defaults
 func (ctx *Context) SetMemory(memory int) *Context {
@@ -276,6 +307,62 @@ func (ctx *Context) GetParallelism() int {
 	return ctx.a2ctx.Parallelism
 }
 
+// sets Context fields. Lanes are the independent memory segments that make up p= in the encoding,
+// so they must match other systems that verify the same hashes. Same as SetParallelism.
+func (ctx *Context) SetLanes(lanes int) *Context {
+	return ctx.SetParallelism(lanes)
+}
+
+// gets Context fields
+func (ctx *Context) GetLanes() int {
+	return ctx.a2ctx.Parallelism
+}
+
+// sets Context fields. The number of threads a hash runs on, which unlike the lanes does not affect the output,
+// so a container with one CPU can verify hashes made with p=4. libargon2 uses at most one thread per lane.
+// 0, the default, runs one thread per lane.
+func (ctx *Context) SetThreads(threads int) *Context {
+	ctx.threads = threads
+	return ctx
+}
+
+// gets Context fields
+func (ctx *Context) GetThreads() int {
+	if ctx.threads > 0 && ctx.threads < ctx.a2ctx.Parallelism {
+		return ctx.threads
+	}
+	return ctx.a2ctx.Parallelism
+}
+
+// sets Context fields from defaults
+func (ctx *Context) SetHashLen(hashLen int) *Context {
+	ctx.a2ctx.HashLen = hashLen
+	return ctx
+}
+
+// gets Context fields
+func (ctx *Context) GetHashLen() int {
+	return ctx.a2ctx.HashLen
+}
+
+// sets Context fields. VerifyEncoded refuses with ErrVerifyLimit, before hashing, encodings whose memory,
+// iterations or parallelism exceed these, so a tampered or foreign hash cannot tie up the server. 0 means no limit.
+func (ctx *Context) SetVerifyLimits(memory int, iterations int, parallelism int) *Context {
+	ctx.verifyLimit = Params{Memory: memory, Iterations: iterations, Parallelism: parallelism}
+	return ctx
+}
+
+// gets Context fields
+func (ctx *Context) GetParams() Params {
+	return Params{
+		Mode:        ctx.a2ctx.Mode,
+		Version:     ctx.a2ctx.Version,
+		Memory:      ctx.a2ctx.Memory,
+		Iterations:  ctx.a2ctx.Iterations,
+		Parallelism: ctx.a2ctx.Parallelism,
+		HashLen:     ctx.a2ctx.HashLen,
+	}
+}
 
 // sets Context fields from defaults
 func (ctx *Context) SetFlags(flags int) *Context {
@@ -284,6 +371,15 @@ func (ctx *Context) SetFlags(flags int) *Context {
 	return ctx
 }
 
+// Clone returns a copy of the Context that can be changed, for example by VerifyEncoded, without affecting ctx.
+// The Secret and AssociatedData slices are shared.
+func (ctx *Context) Clone() *Context {
+	c := *ctx
+	a2ctx := *ctx.a2ctx
+	c.a2ctx = &a2ctx
+	return &c
+}
+
 // sets Context fields from A2Context
 func (ctx *Context) SetFromA2Context(compat *A2Context) *Context {
 	ctx.a2ctx = (*argon2.Context)(compat)
@@ -293,27 +389,58 @@ func (ctx *Context) SetFromA2Context(compat *A2Context) *Context {
 	return ctx
 }
 
-// sets Context fields from encoded string and return binary hash in encoding.
+// sets Context fields, including the hash length, from encoded string and return binary hash in encoding.
 func (ctx *Context) SetFromEncoded(encoded string) (hash []byte, salt []byte, err error) {
+	hash, salt, err = ctx.setFromEncoded(encoded)
+	if err != nil {
+		ctx.logParseError(encoded, err)
+	}
+	return hash, salt, err
+}
+
+func (ctx *Context) setFromEncoded(encoded string) (hash []byte, salt []byte, err error) {
+	//accept the variants written by other libraries, see DecodeFormat
+	_, encoded = DecodeFormat(encoded)
 	var parts []string = strings.Split(encoded, "$")
+	//libargon2 before 1.3 omits the v= part, which then means version 1.0
+	var noVersion bool = len(parts) == 5
+	if noVersion {
+		parts = []string{parts[0], parts[1], "", parts[2], parts[3], parts[4]}
+	}
 	if len(parts) != 6 {
 		return nil, nil, ErrEncodedFormatNotSixParts
 	}
+	if parts[0] != "" {
+		// a prefix DecodeFormat did not accept, such as {ARGON2I} in front of an argon2id hash
+		return nil, nil, ErrEncodedFormat
+	}
 
-	mode, err := argon2_string2type(parts[1])
+	//a legacy hash wrapped by WrapLegacy has a type like argon2id-wrap-bcrypt
+	atype, wrap, _ := strings.Cut(parts[1], "-wrap-")
+	mode, err := argon2_string2type(atype)
 	if err != nil {
 		return nil, nil, ErrEncodedFormatUnknownType
 	}
+	if _, found := legacyWrappers[wrap]; wrap != "" && !found {
+		return nil, nil, ErrEncodedFormatUnknownType
+	}
+	ctx.wrap = wrap
+	ctx.wrapSetting = nil
 
 	ctx.a2ctx = argon2.NewContext(mode)
 	var n int = 0
-	n, err = fmt.Sscanf(parts[2], "v=%d", &ctx.a2ctx.Version)
-	if n != 1 || err != nil {
-		return nil, nil, ErrEncodedFormatNoV
+	ctx.omitVersion = noVersion
+	if noVersion {
+		ctx.a2ctx.Version = Version10
+	} else {
+		n, err = fmt.Sscanf(parts[2], "v=%d", &ctx.a2ctx.Version)
+		if n != 1 || err != nil {
+			return nil, nil, ErrEncodedFormatNoV
+		}
 	}
 	//m,t,p
 	var mtp []string = strings.Split(parts[3], ",")
-	if len(mtp) != 3 {
+	if len(mtp) < 3 {
 		return nil, nil, ErrEncodedFormatNotThreeSubParts
 	}
 	n, err = fmt.Sscanf(mtp[0], "m=%d", &ctx.a2ctx.Memory)
@@ -328,56 +455,230 @@ func (ctx *Context) SetFromEncoded(encoded string) (hash []byte, salt []byte, er
 	if n != 1 || err != nil {
 		return nil, nil, ErrEncodedFormatNoP
 	}
+	//optional parameters after m,t,p
+	ctx.adMarker = ""
+	ctx.keyID = nil
+	ctx.normalization = ""
+	ctx.preHash = false
+	for _, opt := range mtp[3:] {
+		err = ctx.setEncodedOption(opt)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 	ctx.a2ctx.Secret = ctx.Secret
 	ctx.a2ctx.AssociatedData = ctx.AssociatedData
 	ctx.a2ctx.Flags = ctx.Flags
 
 	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, err
+	}
 	hash, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err == nil && len(hash) > 0 {
+		//verify with the hash length of the encoding
+		ctx.a2ctx.HashLen = len(hash)
+	}
 
 	return hash, salt, err
 }
 
 // hash password and salt
 func (ctx *Context) Hash(password []byte, salt []byte) (hash []byte, err error) {
-	mutex.Lock()
-	defer mutex.Unlock()
-	hash, err = argon2.Hash(ctx.a2ctx, password, salt)
+	_, err = ctx.run(OpHash, func() (bool, error) {
+		var err error
+		hash, err = ctx.argon2Hash(password, salt)
+		return err == nil, err
+	})
 	return hash, err
 }
 
+// argon2Hash runs libargon2 through go-argon2, or directly for a thread count other than the lanes or when the
+// memory pool is on. The caller holds the mutex.
+func (ctx *Context) argon2Hash(password []byte, salt []byte) ([]byte, error) {
+	if ctx.threads > 0 || memoryPool {
+		return hashLibargon2(ctx.a2ctx, ctx.threads, memoryPool, password, salt)
+	}
+	return argon2.Hash(ctx.a2ctx, password, salt)
+}
+
 // HashEncoded hashes a password and produces a crypt-like encoded string.
+// It fails with PasswordViolations if the Context has a password policy that password does not meet.
 func (ctx *Context) HashEncoded(password []byte, salt []byte) (string, error) {
+	return ctx.hashEncoded(password, salt)
+}
+
+// hashEncoded normalizes password, checks it against the password policy with the given context words,
+// pre-hashes it and hashes it, each step once.
+func (ctx *Context) hashEncoded(password []byte, salt []byte, context ...string) (string, error) {
+	password, err := ctx.normalizePassword(password)
+	if err != nil {
+		return "", err
+	}
+	err = ctx.checkPasswordPolicy(password, context...)
+	if err != nil {
+		return "", err
+	}
+	password = ctx.preHashPassword(password)
 
 	h, e := ctx.Hash(password, salt)
 
-	var encoded string = fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
-		argon2_type2string(ctx.a2ctx.Mode),
-		ctx.a2ctx.Version,
+	return ctx.encode(salt, h), e
+}
+
+// encode produces the crypt-like encoding of hash and salt using the Context parameters.
+func (ctx *Context) encode(salt []byte, hash []byte) string {
+	options := ctx.encodedOptions()
+	if ctx.normalization != "" {
+		options += ",norm=" + ctx.normalization
+	}
+	if ctx.preHash {
+		options += ",pre=" + preHashBLAKE2b
+	}
+	return ctx.encodeAs(argon2_type2string(ctx.a2ctx.Mode), options, salt, hash)
+}
+
+// encodeAs produces the encoding with the given type and optional parameters.
+func (ctx *Context) encodeAs(atype string, options string, salt []byte, hash []byte) string {
+	var version string = fmt.Sprintf("$v=%d", ctx.a2ctx.Version)
+	if ctx.omitVersion && ctx.a2ctx.Version == Version10 {
+		version = ""
+	}
+	return fmt.Sprintf("$%s%s$m=%d,t=%d,p=%d%s$%s$%s",
+		atype,
+		version,
 		ctx.a2ctx.Memory,
 		ctx.a2ctx.Iterations,
 		ctx.a2ctx.Parallelism,
+		options,
 		base64.RawStdEncoding.EncodeToString(salt),
-		base64.RawStdEncoding.EncodeToString(h))
+		base64.RawStdEncoding.EncodeToString(hash))
+}
 
-	return encoded, e
+// encodedOptions returns the optional parameters that follow m,t,p in the encoding, each with a leading comma.
+func (ctx *Context) encodedOptions() string {
+	var opts string
+	if ctx.encodeHints && len(ctx.Secret) > 0 {
+		opts += ",keyid=" + base64.RawStdEncoding.EncodeToString(secretFingerprint(ctx.Secret))
+	}
+	marker := ctx.adMarker
+	if marker == "" && ctx.encodeHints && len(ctx.AssociatedData) > 0 {
+		marker = adMarkerData
+	}
+	if marker != "" {
+		opts += ",ad=" + base64.RawStdEncoding.EncodeToString([]byte(marker))
+	}
+	return opts
+}
+
+// setEncodedOption sets Context fields from an optional name=value parameter of an encoding.
+func (ctx *Context) setEncodedOption(opt string) error {
+	name, value, found := strings.Cut(opt, "=")
+	if !found {
+		return ErrEncodedFormatBadParameter
+	}
+	switch name {
+	case "keyid":
+		keyID, err := base64.RawStdEncoding.DecodeString(value)
+		if err != nil || len(keyID) == 0 {
+			return ErrEncodedFormatBadParameter
+		}
+		ctx.keyID = keyID
+		ctx.encodeHints = true
+	case "ad":
+		marker, err := base64.RawStdEncoding.DecodeString(value)
+		if err != nil || len(marker) == 0 {
+			return ErrEncodedFormatBadParameter
+		}
+		ctx.adMarker = string(marker)
+	case "wrap":
+		setting, err := base64.RawStdEncoding.DecodeString(value)
+		if err != nil || len(setting) == 0 {
+			return ErrEncodedFormatBadParameter
+		}
+		ctx.wrapSetting = setting
+	case "norm":
+		return ctx.setNormalizationOption(value)
+	case "pre":
+		if value != preHashBLAKE2b {
+			return ErrEncodedFormatBadParameter
+		}
+		ctx.preHash = true
+	default:
+		return ErrEncodedFormatBadParameter
+	}
+	return nil
 }
 
 // Verify verifies an Argon2 hash against a plaintext password.
 func (ctx *Context) Verify(hash, password, salt []byte) (bool, error) {
-	mutex.Lock()
-	defer mutex.Unlock()
-	return argon2.Verify(ctx.a2ctx, hash, password, salt)
+	return ctx.verify(OpVerify, hash, password, salt)
+}
+
+func (ctx *Context) verify(op string, hash, password, salt []byte) (bool, error) {
+	return ctx.run(op, func() (bool, error) {
+		if ctx.threads > 0 || memoryPool {
+			return verifyLibargon2(ctx.a2ctx, ctx.threads, memoryPool, hash, password, salt)
+		}
+		return argon2.Verify(ctx.a2ctx, hash, password, salt)
+	})
 }
 
 // VerifyEncoded verifies an encoded Argon2 hash s against a plaintext password.
 // It mutates the context to match the encoding so unwise to use the same context for encoding and verifying
+// If the encoding records a secret fingerprint or associated data (see SetEncodeHints) that the Context cannot match
+// it returns ErrWrongSecret or ErrMissingAssociatedData instead of hashing.
+// A legacy hash wrapped by WrapLegacy is verified by computing the legacy hash of password first.
+// A normalization recorded as norm= (see SetNormalization) and a pre-hash recorded as pre= (see SetPreHash)
+// are applied to password before anything else.
 func (ctx *Context) VerifyEncoded(s string, password []byte) (bool, error) {
-	hash, salt, err := ctx.SetFromEncoded(s)
+	defer ctx.restoreSecret()
+	hash, salt, err := ctx.decodeForVerify(s)
+	if err == nil {
+		password, err = ctx.preparePassword(password)
+	}
+	if err == nil && ctx.wrap != "" {
+		password, err = ctx.legacyStep(password)
+	}
 	if err != nil {
+		ctx.observe(&Observation{Op: OpVerifyEncoded, Outcome: OutcomeError, Err: err})
 		return false, err
 	}
-	return ctx.Verify(hash, password, salt)
+	return ctx.verify(OpVerifyEncoded, hash, password, salt)
+}
+
+// decodeForVerify sets Context fields from the encoding and checks the encoded hints against the Context.
+func (ctx *Context) decodeForVerify(s string) (hash []byte, salt []byte, err error) {
+	before := ctx.GetParams()
+	hash, salt, err = ctx.SetFromEncoded(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ctx.GetParams() != before {
+		ctx.logDecision(slog.LevelDebug, "verifying with parameters from encoding",
+			slog.Any("previous", before))
+	}
+	err = ctx.checkVerifyLimits()
+	if err != nil {
+		return nil, nil, err
+	}
+	err = ctx.checkHints()
+	if err != nil {
+		return nil, nil, err
+	}
+	return hash, salt, nil
+}
+
+// checkVerifyLimits compares the parameters of the last encoding with SetVerifyLimits.
+func (ctx *Context) checkVerifyLimits() error {
+	limit := ctx.verifyLimit
+	if (limit.Memory > 0 && ctx.a2ctx.Memory > limit.Memory) ||
+		(limit.Iterations > 0 && ctx.a2ctx.Iterations > limit.Iterations) ||
+		(limit.Parallelism > 0 && ctx.a2ctx.Parallelism > limit.Parallelism) {
+		ctx.logDecision(slog.LevelWarn, "refusing encoding beyond verify limits", slog.Any("limits", limit))
+		return ErrVerifyLimit
+	}
+	return nil
 }
 
 func (ctx *Context) SetSecrets(password []byte, initialsalt []byte, ssa ...safesecrets.SecretSetter) (err error){
@@ -3,6 +3,7 @@ package argon2_go_withsecret
 import (
 	"bytes"
 	"encoding/hex"
+	"strings"
 	"testing"
 )
 
@@ -242,7 +243,7 @@ func testVerifyEncoded(t *testing.T, ctx *Context) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	t.Logf("encoded=%s ctx=%+v a2ctx=%+v", s, ctx, ctx.a2ctx)
+	t.Logf("encoded=%s ctx=%+v", s, ctx)
 	pw := []byte("somepassword")
 	ok, err := ctx.VerifyEncoded(s, pw)
 	if err != nil {
@@ -250,7 +251,7 @@ func testVerifyEncoded(t *testing.T, ctx *Context) {
 		t.Fatal(err)
 	}
 	if !ok {
-		t.Logf("encoded=%s ctx=%+v a2ctx=%+v", s, ctx, ctx.a2ctx)
+		t.Logf("encoded=%s ctx=%+v", s, ctx)
 		t.Errorf("VerifyEncoded(s, []byte(%q)) = false  want true", pw)
 	}
 
@@ -299,3 +300,89 @@ func testVerify(t *testing.T, ctx *Context) {
 		t.Errorf("Verify(badsalt) = true  want false (%v)", ctx)
 	}
 }
+
+func TestVerifyEncodedNoVersion(t *testing.T) {
+	// as emitted by libargon2 before 1.3 for the v=16 vector in TestHashEncoded
+	legacy := "$argon2d$m=65536,t=3,p=2$c29tZXNhbHQ$CykrV8U+ZdXKv/r9fxmofesmRD/pWZRyvZvn+TgucPQ"
+
+	ctx := NewContext()
+	ok, err := ctx.VerifyEncoded(legacy, []byte("somepassword"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Errorf("VerifyEncoded(legacy) = false  want true")
+	}
+	if ctx.GetVersion() != Version10 {
+		t.Errorf("got version %x  want %x", ctx.GetVersion(), Version10)
+	}
+
+	s, err := ctx.HashEncoded([]byte("somepassword"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != legacy {
+		t.Errorf("re-encoding: got %q  want %q", s, legacy)
+	}
+
+	ctx.UpgradeEncoding()
+	s, err = ctx.HashEncoded([]byte("somepassword"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "$argon2d$v=19$m=65536,t=3,p=2$c29tZXNhbHQ$YU0Z8m2oBVAEb6myikm/FvEKU+/pETdeWHjxD9AMsIs"
+	if s != expected {
+		t.Errorf("upgraded: got %q  want %q", s, expected)
+	}
+}
+
+func TestLanes(t *testing.T) {
+	ctx := NewContext().SetMemory(1 << 10).SetLanes(4)
+	if ctx.GetLanes() != 4 || ctx.GetParallelism() != 4 || ctx.GetThreads() != 4 {
+		t.Errorf("got lanes %d, parallelism %d, threads %d", ctx.GetLanes(), ctx.GetParallelism(), ctx.GetThreads())
+	}
+	s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s, ",p=4$") {
+		t.Errorf("p= does not reflect lanes: %s", s)
+	}
+
+	// threads do not change the hash
+	ctx.SetThreads(1)
+	if ctx.GetLanes() != 4 || ctx.GetThreads() != 1 {
+		t.Errorf("got lanes %d, threads %d  want 4, 1", ctx.GetLanes(), ctx.GetThreads())
+	}
+	s1, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1 != s {
+		t.Errorf("1 thread: got %s  want %s", s1, s)
+	}
+	ok, err := NewContext().SetThreads(1).VerifyEncoded(refArgon2id, []byte("password"))
+	if !ok || err != nil {
+		t.Errorf("VerifyEncoded with 1 thread = %v, %v  want true", ok, err)
+	}
+	if ctx.SetThreads(8).GetThreads() != 4 {
+		t.Errorf("got %d threads for 4 lanes", ctx.GetThreads())
+	}
+}
+
+func TestVerifyEncodedHashLen(t *testing.T) {
+	for _, hashLen := range []int{16, 64} {
+		s, err := NewContext().SetMemory(1<<10).SetHashLen(hashLen).HashEncoded([]byte("password"), []byte("somesalt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := NewContext()
+		ok, err := ctx.VerifyEncoded(s, []byte("password"))
+		if !ok || err != nil {
+			t.Errorf("%d bytes: got %v, %v  want true", hashLen, ok, err)
+		}
+		if ctx.GetHashLen() != hashLen {
+			t.Errorf("%d bytes: hash length %d after VerifyEncoded", hashLen, ctx.GetHashLen())
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package argon2_go_withsecret
+
+import (
+	"math"
+)
+
+// argon2Traffic is the memory traffic of computing one block: two blocks read and one written.
+const argon2Traffic = 3
+
+// Hardware describes a device an attacker can rent to guess passwords.
+type Hardware struct {
+	Name         string  `json:"name"`
+	Cores        int     `json:"cores"`          // hashes the device can compute at once, memory permitting
+	CoreRate     float64 `json:"core_rate"`      // bytes of Argon2 memory one core fills per second
+	Memory       float64 `json:"memory"`         // bytes of device memory
+	Bandwidth    float64 `json:"bandwidth"`      // bytes per second of device memory bandwidth
+	PricePerHour float64 `json:"price_per_hour"` // dollars
+}
+
+// DefaultHardware is an illustrative table of rentable hardware. The figures are approximate public
+// specifications and on-demand prices at the time of writing; use your own for decisions.
+var DefaultHardware = []Hardware{
+	{"aws-g3.16xlarge (4x Tesla M60)", 8192, 50e6, 32 << 30, 640e9, 4.56},
+	{"rtx-4090 rental", 16384, 100e6, 24 << 30, 1008e9, 0.70},
+	{"64 core cpu server", 128, 1e9, 512 << 30, 400e9, 3.00},
+}
+
+// CostModel estimates what guessing passwords hashed with given parameters costs an attacker.
+// A guess needs the full memory of the hash for its duration, so the number of guesses in flight is limited by
+// the cores and by the device memory, and their speed by the core rate and the memory bandwidth.
+type CostModel struct {
+	Hardware []Hardware
+}
+
+// CostEstimate is the attacker cost of one hardware profile.
+type CostEstimate struct {
+	Hardware         Hardware
+	Parallel         int     // guesses in flight
+	Bound            string  // what limits the guess rate: "memory", "cores" or "bandwidth"
+	GuessesPerSecond float64 // 0 if a single hash does not fit in the device memory
+	DollarsPerGuess  float64 // +Inf if a single hash does not fit in the device memory
+}
+
+// NewCostModel returns a cost model over DefaultHardware.
+func NewCostModel() *CostModel {
+	return &CostModel{Hardware: DefaultHardware}
+}
+
+// Estimate returns the cost estimate of each hardware profile for p.
+func (cm *CostModel) Estimate(p Params) []CostEstimate {
+	estimates := make([]CostEstimate, 0, len(cm.Hardware))
+	for _, hw := range cm.Hardware {
+		estimates = append(estimates, estimate(hw, p))
+	}
+	return estimates
+}
+
+func estimate(hw Hardware, p Params) CostEstimate {
+	e := CostEstimate{Hardware: hw, DollarsPerGuess: math.Inf(1)}
+	memory := float64(p.Memory) * 1024
+	work := memory * float64(max(p.Iterations, 1))
+	if memory <= 0 || hw.Memory < memory {
+		e.Bound = "memory"
+		return e
+	}
+	fit := int(hw.Memory / memory)
+	e.Parallel, e.Bound = fit, "memory"
+	if hw.Cores < fit {
+		e.Parallel, e.Bound = hw.Cores, "cores"
+	}
+	e.GuessesPerSecond = float64(e.Parallel) * hw.CoreRate / work
+	if limit := hw.Bandwidth / (argon2Traffic * work); limit < e.GuessesPerSecond {
+		e.GuessesPerSecond, e.Bound = limit, "bandwidth"
+	}
+	if e.GuessesPerSecond > 0 {
+		e.DollarsPerGuess = hw.PricePerHour / 3600 / e.GuessesPerSecond
+	}
+	return e
+}
+
+// SearchCost returns the expected dollars to find a password among candidates equally likely guesses.
+// With a secret of secretBits random bits that the attacker does not have, every password guess must be tried with
+// every possible secret, multiplying the cost by 2^secretBits. Zero secretBits models a stolen or absent secret.
+func (e CostEstimate) SearchCost(candidates float64, secretBits int) float64 {
+	return e.DollarsPerGuess * candidates / 2 * math.Exp2(float64(secretBits))
+}
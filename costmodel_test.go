@@ -0,0 +1,45 @@
+package argon2_go_withsecret
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCostModel(t *testing.T) {
+	hw := Hardware{Name: "test", Cores: 100, CoreRate: 1 << 30, Memory: 16 << 30, Bandwidth: 100 << 30, PricePerHour: 3.6}
+	cm := &CostModel{Hardware: []Hardware{hw}}
+	vectors := []struct {
+		params   Params
+		parallel int
+		bound    string
+		guesses  float64
+	}{
+		// 64 MiB: 100 cores fit in memory, each filling 1 GiB/s, but bandwidth allows only 100 GiB/s / 3 / 192 MiB
+		{Params{Memory: 1 << 16, Iterations: 3}, 100, "bandwidth", 100 * 1024 / 3.0 / 192},
+		// 1 GiB: memory allows 16 in flight
+		{Params{Memory: 1 << 20, Iterations: 1}, 16, "memory", 16},
+		// 32 GiB does not fit
+		{Params{Memory: 1 << 25, Iterations: 1}, 0, "memory", 0},
+	}
+	for i, v := range vectors {
+		e := cm.Estimate(v.params)[0]
+		if e.Parallel != v.parallel || e.Bound != v.bound || math.Abs(e.GuessesPerSecond-v.guesses) > 1e-9 {
+			t.Errorf("%d: got %d %s %g  want %d %s %g", i, e.Parallel, e.Bound, e.GuessesPerSecond,
+				v.parallel, v.bound, v.guesses)
+		}
+	}
+
+	e := cm.Estimate(Params{Memory: 1 << 20, Iterations: 1})[0]
+	if got := e.DollarsPerGuess; math.Abs(got-1.0/16/1000) > 1e-12 {
+		t.Errorf("$/guess: got %g", got)
+	}
+	if got := e.SearchCost(2000, 0); math.Abs(got-1.0/16) > 1e-12 {
+		t.Errorf("search: got %g", got)
+	}
+	if got := e.SearchCost(2000, 128); got < 1e37 {
+		t.Errorf("search with secret: got %g", got)
+	}
+	if e := cm.Estimate(Params{Memory: 1 << 25, Iterations: 1})[0]; !math.IsInf(e.DollarsPerGuess, 1) {
+		t.Errorf("too large: got %g", e.DollarsPerGuess)
+	}
+}
@@ -0,0 +1,70 @@
+package argon2_go_withsecret
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// throughputWeight is the weight of the newest hash in the moving average of hash speed.
+const throughputWeight = 0.2
+
+// throughputKey is what hash speed is averaged over: the lanes and the threads they run on.
+type throughputKey struct {
+	lanes   int
+	threads int
+}
+
+var (
+	throughputMutex sync.Mutex
+	throughput      = map[throughputKey]float64{} // nanoseconds per KiB per iteration
+)
+
+// throughputKey returns the key of the hash speed of the Context.
+func (ctx *Context) throughputKey() throughputKey {
+	return throughputKey{lanes: ctx.a2ctx.Parallelism, threads: ctx.GetThreads()}
+}
+
+// recordThroughput updates the moving average of hash speed for the lanes and threads of key.
+func recordThroughput(key throughputKey, o *Observation) {
+	work := float64(o.Params.Memory) * float64(o.Params.Iterations)
+	if o.Err != nil || o.Compute <= 0 || work <= 0 {
+		return
+	}
+	ns := float64(o.Compute) / work
+	throughputMutex.Lock()
+	defer throughputMutex.Unlock()
+	if old, found := throughput[key]; found {
+		ns = old + throughputWeight*(ns-old)
+	}
+	throughput[key] = ns
+}
+
+// observedThroughput returns the average nanoseconds per KiB per iteration of recent hashes with the lanes and
+// threads of key.
+func observedThroughput(key throughputKey) (float64, bool) {
+	throughputMutex.Lock()
+	defer throughputMutex.Unlock()
+	ns, found := throughput[key]
+	return ns, found
+}
+
+// HashEncodedWithin hashes password with a new salt and as many iterations as fit in budget, judged from the speed
+// of recent hashes on this host with the same lanes and threads. The Context iterations are the minimum, used as is
+// until a hash has been observed, and its verify limit, if any, is the maximum. The chosen t is recorded in the
+// encoding as usual. Queueing on the package mutex is not part of the budget. The Context is not changed.
+func (ctx *Context) HashEncodedWithin(password []byte, budget time.Duration) (string, error) {
+	c := ctx.Clone()
+	if ns, found := observedThroughput(c.throughputKey()); found {
+		t := int(float64(budget) / (ns * float64(c.a2ctx.Memory)))
+		if limit := c.verifyLimit.Iterations; limit > 0 {
+			t = min(t, limit)
+		}
+		if t > c.a2ctx.Iterations {
+			c.a2ctx.Iterations = t
+			c.logDecision(slog.LevelDebug, "raising iterations to fit budget",
+				slog.Duration("budget", budget), slog.Int("minimum", ctx.a2ctx.Iterations))
+		}
+	}
+	return c.HashEncodedRandomSalt(password)
+}
@@ -0,0 +1,86 @@
+package argon2_go_withsecret
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashEncodedWithin(t *testing.T) {
+	// lanes not used by other tests, with a known speed of 1µs per KiB per iteration
+	const lanes = 7
+	ctx := NewContext().SetMemory(1 << 10).SetLanes(lanes).SetIterations(2)
+	setThroughput := func() {
+		throughputMutex.Lock()
+		throughput[ctx.throughputKey()] = 1000
+		throughputMutex.Unlock()
+	}
+
+	setThroughput()
+	s, err := ctx.HashEncodedWithin([]byte("password"), 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s, ",t=4,") || ctx.GetIterations() != 2 {
+		t.Errorf("5ms: got %s, context t=%d", s, ctx.GetIterations())
+	}
+	if ok, err := NewContext().VerifyEncoded(s, []byte("password")); !ok || err != nil {
+		t.Errorf("verify: got %v, %v", ok, err)
+	}
+
+	setThroughput()
+	s, _ = ctx.HashEncodedWithin([]byte("password"), time.Microsecond)
+	if !strings.Contains(s, ",t=2,") {
+		t.Errorf("below minimum: got %s", s)
+	}
+
+	setThroughput()
+	s, _ = ctx.Clone().SetVerifyLimits(0, 3, 0).HashEncodedWithin([]byte("password"), 5*time.Millisecond)
+	if !strings.Contains(s, ",t=3,") {
+		t.Errorf("verify limit: got %s", s)
+	}
+
+	throughputMutex.Lock()
+	delete(throughput, ctx.throughputKey())
+	throughputMutex.Unlock()
+	s, _ = ctx.HashEncodedWithin([]byte("password"), time.Second)
+	if !strings.Contains(s, ",t=2,") {
+		t.Errorf("no observations: got %s", s)
+	}
+	if _, found := observedThroughput(ctx.throughputKey()); !found {
+		t.Error("hash speed not recorded")
+	}
+}
+
+func TestHashEncodedWithinThreads(t *testing.T) {
+	// lanes not used by other tests, known to hash at 1µs per KiB per iteration on all of them
+	const lanes = 6
+	ctx := NewContext().SetMemory(1 << 10).SetLanes(lanes).SetIterations(2)
+	throughputMutex.Lock()
+	throughput[ctx.throughputKey()] = 1000
+	throughputMutex.Unlock()
+	t.Cleanup(func() {
+		throughputMutex.Lock()
+		delete(throughput, ctx.throughputKey())
+		delete(throughput, ctx.Clone().SetThreads(1).throughputKey())
+		throughputMutex.Unlock()
+	})
+
+	// a verify on one thread is slower and must not change the estimate for six
+	one := ctx.Clone().SetThreads(1)
+	recordThroughput(one.throughputKey(), &Observation{Params: one.GetParams(), Compute: time.Second})
+	if ns, _ := observedThroughput(ctx.throughputKey()); ns != 1000 {
+		t.Errorf("six threads: got %v ns  want 1000", ns)
+	}
+	s, err := ctx.HashEncodedWithin([]byte("password"), 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s, ",t=4,") {
+		t.Errorf("six threads: got %s", s)
+	}
+	s, _ = one.HashEncodedWithin([]byte("password"), 5*time.Millisecond)
+	if !strings.Contains(s, ",t=2,") {
+		t.Errorf("one thread: got %s", s)
+	}
+}
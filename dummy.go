@@ -0,0 +1,29 @@
+package argon2_go_withsecret
+
+// Fixed, pre-generated salt and hash used to build the dummy encoding.
+// No password hashes to them so a dummy verification can never succeed.
+var (
+	dummySalt = []byte{0x75, 0x8e, 0x7e, 0xd1, 0x06, 0xa5, 0xd3, 0xaa, 0x13, 0x1a, 0x28, 0x3d, 0x2b, 0x31, 0x2d, 0x77}
+	dummyHash = []byte{
+		0x71, 0x97, 0x66, 0xa9, 0x51, 0xcf, 0xaf, 0x0d, 0x46, 0x54, 0xd1, 0x47, 0xd4, 0xb5, 0x84, 0x18,
+		0x81, 0x2b, 0x69, 0x06, 0xed, 0x67, 0x9e, 0xdf, 0x3f, 0x49, 0x30, 0x4f, 0x5c, 0x58, 0xce, 0x89}
+)
+
+// DummyEncoded returns a fake encoded hash carrying the current Context parameters.
+// It parses and verifies exactly like a real stored hash.
+func (ctx *Context) DummyEncoded() string {
+	hash := make([]byte, ctx.a2ctx.HashLen)
+	for i := range hash {
+		hash[i] = dummyHash[i%len(dummyHash)]
+	}
+	return ctx.encode(dummySalt, hash)
+}
+
+// VerifyMissingUser is called in place of VerifyEncoded when the user does not exist.
+// It verifies password against DummyEncoded, so it costs the same time, waits in the same queue
+// and is observed like a real failed verification, without revealing that the account is missing.
+// It always returns false; an error is returned only when a real verification would also fail with one.
+func (ctx *Context) VerifyMissingUser(password []byte) (bool, error) {
+	_, err := ctx.VerifyEncoded(ctx.DummyEncoded(), password)
+	return false, err
+}
@@ -0,0 +1,30 @@
+package argon2_go_withsecret
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyMissingUser(t *testing.T) {
+	rec := &recordingObserver{}
+	ctx := NewContext().SetMemory(1 << 10).SetIterations(2).SetSecret([]byte("somesecret")).SetObserver(rec)
+
+	encoded := ctx.DummyEncoded()
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=2,p=2$") {
+		t.Errorf("dummy encoding does not carry context parameters: %s", encoded)
+	}
+
+	ok, err := ctx.VerifyMissingUser([]byte("somepassword"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Errorf("VerifyMissingUser = true  want false")
+	}
+	if len(rec.seen) != 1 || rec.seen[0].Op != OpVerifyEncoded || rec.seen[0].Outcome != OutcomeMismatch {
+		t.Errorf("dummy verification not observed as a failed verify: %+v", rec.seen)
+	}
+	if rec.seen[0].Params != ctx.GetParams() || ctx.DummyEncoded() != encoded {
+		t.Errorf("dummy verification changed context parameters")
+	}
+}
@@ -10,7 +10,7 @@ import (
 func example1() {
 
 	ctx := argon2_go_withsecret.NewContext()
-	s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	s, err := ctx.HashEncodedRandomSalt([]byte("password"))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -30,7 +30,7 @@ func example2() {
 	ctx.SetMemory(1 << 18)
 	ctx.SetParallelism(2)
 	ctx.SetSecret([]byte("secret"))
-	s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	s, err := ctx.HashEncodedRandomSalt([]byte("password"))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -53,7 +53,7 @@ func example3() {
     ctx.SetMemory(1 << 18)
     ctx.SetParallelism(1)
     ctx.SetSecret([]byte("secret"))
-    s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+    s, err := ctx.HashEncodedRandomSalt([]byte("password"))
     if err != nil {
         log.Fatal(err)
     }
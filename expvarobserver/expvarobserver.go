@@ -0,0 +1,107 @@
+// Package expvarobserver publishes argon2_go_withsecret observations as expvar counters and histograms.
+//
+//	argon2_go_withsecret.SetDefaultObserver(expvarobserver.New("argon2"))
+//
+// The values then appear under /debug/vars as
+//
+//	"argon2": {"ops": {"hash.ok": 10, "verifyencoded.mismatch": 2, ...},
+//	           "queue_wait_ms": {...}, "compute_ms": {...}, "memory_kib": 65536}
+package expvarobserver
+
+import (
+	"encoding/json"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/learnfromgirls/argon2-go-withsecret"
+)
+
+// DefaultBucketsMs are the upper bounds, in milliseconds, of the histogram buckets.
+var DefaultBucketsMs = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Observer implements argon2_go_withsecret.Observer on top of expvar.
+type Observer struct {
+	Ops       *expvar.Map // count per "op.outcome"
+	QueueWait *Histogram  // time waiting for the package mutex
+	Compute   *Histogram  // time spent hashing
+	MemoryKiB *expvar.Int // memory parameter of the last operation
+}
+
+// New creates an Observer and publishes it under name.
+// Like expvar.Publish it panics if name is already in use.
+func New(name string) *Observer {
+	o := &Observer{
+		Ops:       new(expvar.Map).Init(),
+		QueueWait: NewHistogram(DefaultBucketsMs),
+		Compute:   NewHistogram(DefaultBucketsMs),
+		MemoryKiB: new(expvar.Int),
+	}
+	m := expvar.NewMap(name)
+	m.Set("ops", o.Ops)
+	m.Set("queue_wait_ms", o.QueueWait)
+	m.Set("compute_ms", o.Compute)
+	m.Set("memory_kib", o.MemoryKiB)
+	return o
+}
+
+func (o *Observer) Observe(obs *argon2_go_withsecret.Observation) {
+	o.Ops.Add(obs.Op+"."+obs.Outcome.String(), 1)
+	o.QueueWait.Observe(obs.QueueWait)
+	if obs.Compute > 0 {
+		o.Compute.Observe(obs.Compute)
+	}
+	o.MemoryKiB.Set(int64(obs.Params.Memory))
+}
+
+// Histogram is a bucketed histogram of durations that satisfies expvar.Var.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64 // ms, ascending
+	counts []int64   // len(bounds)+1, the last is the overflow bucket
+	count  int64
+	sumMs  float64
+}
+
+// NewHistogram creates a Histogram with the given ascending bucket upper bounds in milliseconds.
+func NewHistogram(boundsMs []float64) *Histogram {
+	return &Histogram{
+		bounds: append([]float64(nil), boundsMs...),
+		counts: make([]int64, len(boundsMs)+1),
+	}
+}
+
+func (h *Histogram) Observe(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := 0
+	for i < len(h.bounds) && ms > h.bounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sumMs += ms
+}
+
+// String returns the histogram as JSON, as required by expvar.Var.
+func (h *Histogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	buckets := make(map[string]int64, len(h.counts))
+	for i, b := range h.bounds {
+		buckets[formatBound(b)] = h.counts[i]
+	}
+	buckets["+Inf"] = h.counts[len(h.bounds)]
+	b, _ := json.Marshal(struct {
+		Count   int64            `json:"count"`
+		SumMs   float64          `json:"sum"`
+		Buckets map[string]int64 `json:"buckets"`
+	}{h.count, h.sumMs, buckets})
+	return string(b)
+}
+
+func formatBound(b float64) string {
+	out, _ := json.Marshal(b)
+	return string(out)
+}
@@ -0,0 +1,59 @@
+package expvarobserver
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/learnfromgirls/argon2-go-withsecret"
+)
+
+func TestObserver(t *testing.T) {
+	o := New("argon2test")
+	ctx := argon2_go_withsecret.NewContext().SetMemory(1 << 10).SetObserver(o)
+
+	s, err := ctx.HashEncoded([]byte("somepassword"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.VerifyEncoded(s, []byte("wrongpassword"))
+
+	if v := o.Ops.Get("hash.ok"); v == nil || v.String() != "1" {
+		t.Errorf("hash.ok = %v want 1", v)
+	}
+	if v := o.Ops.Get("verifyencoded.mismatch"); v == nil || v.String() != "1" {
+		t.Errorf("verifyencoded.mismatch = %v want 1", v)
+	}
+	if o.MemoryKiB.Value() != 1<<10 {
+		t.Errorf("memory_kib = %d want %d", o.MemoryKiB.Value(), 1<<10)
+	}
+
+	var published map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(expvar.Get("argon2test").String()), &published); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"ops", "queue_wait_ms", "compute_ms", "memory_kib"} {
+		if _, ok := published[k]; !ok {
+			t.Errorf("missing %q in published expvar", k)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]float64{1, 10})
+	h.Observe(500 * time.Microsecond)
+	h.Observe(5 * time.Millisecond)
+	h.Observe(time.Second)
+
+	var got struct {
+		Count   int64            `json:"count"`
+		Buckets map[string]int64 `json:"buckets"`
+	}
+	if err := json.Unmarshal([]byte(h.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Count != 3 || got.Buckets["1"] != 1 || got.Buckets["10"] != 1 || got.Buckets["+Inf"] != 1 {
+		t.Errorf("unexpected histogram %s", h.String())
+	}
+}
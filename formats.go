@@ -0,0 +1,119 @@
+package argon2_go_withsecret
+
+import (
+	"errors"
+	"strings"
+)
+
+// Format identifies how another library writes Argon2 hashes.
+type Format int
+
+const (
+	FormatNative  Format = iota // this package, passlib and Spring Security: $argon2id$v=19$m=65536,t=3,p=2$salt$hash
+	FormatSodium                // libsodium crypto_pwhash_str, argon2i or argon2id version 1.3 only
+	FormatDjango                // Django Argon2PasswordHasher, "argon2" followed by the PHC string
+	FormatLDAP                  // OpenLDAP userPassword, {ARGON2} followed by the PHC string
+	FormatDovecot               // Dovecot, {ARGON2I} or {ARGON2ID} followed by the PHC string
+)
+
+// djangoPrefix is the algorithm name Django puts in front of the PHC string.
+const djangoPrefix = "argon2"
+
+var (
+	ErrFormatSecret = errors.New("argon2-go-withsecret: format cannot carry a secret, associated data or wrapped legacy hash")
+	ErrFormatParams = errors.New("argon2-go-withsecret: parameters not supported by format")
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatSodium:
+		return "sodium"
+	case FormatDjango:
+		return "django"
+	case FormatLDAP:
+		return "ldap"
+	case FormatDovecot:
+		return "dovecot"
+	default:
+		return "native"
+	}
+}
+
+// DecodeFormat recognises an encoding written by another library and returns it in native form.
+// Django's argon2$ prefix, LDAP and Dovecot {SCHEME} prefixes and the NUL padding of libsodium's fixed size buffers
+// are removed. passlib, Spring and libsodium strings are otherwise native PHC strings and are reported as FormatNative.
+func DecodeFormat(encoded string) (Format, string) {
+	if strings.HasPrefix(encoded, djangoPrefix+"$argon2") {
+		return FormatDjango, encoded[len(djangoPrefix):]
+	}
+	if strings.HasPrefix(encoded, "{") {
+		scheme, rest, found := strings.Cut(encoded[1:], "}")
+		if found && strings.HasPrefix(rest, "$argon2") {
+			switch strings.ToUpper(scheme) {
+			case "ARGON2":
+				return FormatLDAP, rest
+			case "ARGON2I", "ARGON2ID":
+				// the scheme names the mode, a mismatch is left for the parser to reject
+				if strings.HasPrefix(rest, "$"+strings.ToLower(scheme)+"$") {
+					return FormatDovecot, rest
+				}
+			}
+		}
+	}
+	if trimmed := strings.TrimRight(encoded, "\x00"); len(trimmed) != len(encoded) {
+		return FormatSodium, trimmed
+	}
+	return FormatNative, encoded
+}
+
+// EncodeFormat converts a native encoding, as returned by HashEncoded, for use by another library.
+// None of the other libraries can supply a secret or associated data, so encodings that need them are
+// refused with ErrFormatSecret rather than exported as hashes that can never verify. A secret can only be
+// detected in an encoding written with SetEncodeHints; HashEncodedFormat checks the Context instead.
+func EncodeFormat(f Format, encoded string) (string, error) {
+	_, encoded = DecodeFormat(encoded)
+	ctx := NewContext()
+	if _, _, err := ctx.setFromEncoded(encoded); err != nil {
+		return "", err
+	}
+	if ctx.keyID != nil || ctx.adMarker != "" || ctx.wrap != "" {
+		return "", ErrFormatSecret
+	}
+	if ctx.normalization != "" || ctx.preHash {
+		// the other libraries would hash the password as given
+		return "", ErrFormatParams
+	}
+	switch f {
+	case FormatSodium:
+		if ctx.a2ctx.Mode == ModeArgon2d || ctx.a2ctx.Version != Version13 || ctx.omitVersion {
+			return "", ErrFormatParams
+		}
+	case FormatDjango:
+		return djangoPrefix + encoded, nil
+	case FormatLDAP:
+		return "{ARGON2}" + encoded, nil
+	case FormatDovecot:
+		switch ctx.a2ctx.Mode {
+		case ModeArgon2i:
+			return "{ARGON2I}" + encoded, nil
+		case ModeArgon2id:
+			return "{ARGON2ID}" + encoded, nil
+		default:
+			return "", ErrFormatParams
+		}
+	}
+	return encoded, nil
+}
+
+// HashEncodedFormat is HashEncoded for another library's format, see EncodeFormat.
+// It fails with ErrFormatSecret before hashing if the Context has a secret or associated data.
+func (ctx *Context) HashEncodedFormat(f Format, password []byte, salt []byte) (string, error) {
+	if len(ctx.Secret) > 0 || len(ctx.AssociatedData) > 0 {
+		return "", ErrFormatSecret
+	}
+	s, err := ctx.HashEncoded(password, salt)
+	if err != nil {
+		return "", err
+	}
+	return EncodeFormat(f, s)
+}
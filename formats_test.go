@@ -0,0 +1,156 @@
+package argon2_go_withsecret
+
+import (
+	"strings"
+	"testing"
+)
+
+// Reference vectors from the argon2 test suite for password "password" and salt "somesalt".
+const (
+	refArgon2i  = "$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA"
+	refArgon2id = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+)
+
+// Known answers written by other libraries, taken from their own test suites.
+var crossLibraryVectors = []struct {
+	library  string
+	password string
+	encoded  string
+	format   Format
+}{
+	// Django tests/auth_tests/test_hashers.py, without and with v=
+	{"django", "secret", "argon2$argon2i$m=8,t=1,p=1$c29tZXNhbHQ$gwQOXSNhxiOxPOA0+PY10P9QFO4NAYysnqRt1GSQLE55m+2GYDt9FEjPMHhP2Cuf0nOEXXMocVrsJAtNSsKyfg", FormatDjango},
+	{"django", "secret", "argon2$argon2i$v=19$m=8,t=1,p=1$c2FsdHNhbHQ$YC9+jJCrQhs5R6db7LlN8Q", FormatDjango},
+	// passlib passlib/tests/test_handlers_argon2.py
+	{"passlib", "password", "$argon2i$v=19$m=256,t=1,p=1$c29tZXNhbHQ$AJFIsNZTMKTAewB4+ETN1A", FormatNative},
+	{"passlib", "password", "$argon2i$v=19$m=380,t=2,p=2$c29tZXNhbHQ$SrssP8n7m/12VWPM8dvNrw", FormatNative},
+	{"passlib", "password", "$argon2d$v=19$m=102400,t=2,p=8$g2RodLh8j8WbSdCp+lUy/A$zzAJqL/HSjm809PYQu6qkA", FormatNative},
+	// libsodium test/default/pwhash_argon2i.c tv3
+	{"sodium", "^T5H$JYt39n%K*j:W]!1s?vg!:jGi]Ax?..l7[p0v:1jHTpla9;]bUN;?bWyCbtqg ", "$argon2i$v=19$m=4096,t=3,p=2$X1NhbHQAAAAAAAAAAAAAAA$z/QMiU4lQxGsYNc/+K/bizwsA1P11UG2dj/7+aILJ4I", FormatNative},
+	// libsodium 1.0.18 crypto_pwhash_str_alg with opslimit 2 and memlimit 64 KiB, the whole crypto_pwhash_STRBYTES buffer
+	{"sodium", "correct horse battery staple", "$argon2id$v=19$m=64,t=2,p=1$JBcGIKvXge44nTVauKcOVA$7DNTNMXyalJBpn/s+d/OkttMGRqzxG1t591uJ2d/Ivg" + strings.Repeat("\x00", 34), FormatSodium},
+	// Spring Security Argon2PasswordEncoderTests, predictable salt with the 5.x and the 5.8 defaults
+	{"spring", "sometestpassword", "$argon2id$v=19$m=4096,t=3,p=1$QUFBQUFBQUFBQUFBQUFBQQ$hmmTNyJlwbb6HAvFoHFWF+u03fdb0F2qA+39oPlcAqo", FormatNative},
+	{"spring", "sometestpassword", "$argon2id$v=19$m=16384,t=2,p=1$QUFBQUFBQUFBQUFBQUFBQQ$zGt5MiNPSUOo4/7jBcJMayCPfcsLJ4c0WUxhwGDIYPw", FormatNative},
+}
+
+func TestDecodeFormat(t *testing.T) {
+	vectors := []struct {
+		encoded string
+		format  Format
+		native  string
+	}{
+		{refArgon2id, FormatNative, refArgon2id},
+		{"argon2" + refArgon2id, FormatDjango, refArgon2id},
+		{"argon2" + refArgon2i, FormatDjango, refArgon2i},
+		{refArgon2id + strings.Repeat("\x00", 128-len(refArgon2id)), FormatSodium, refArgon2id},
+		{"{ARGON2}" + refArgon2id, FormatLDAP, refArgon2id},
+		{"{argon2}" + refArgon2i, FormatLDAP, refArgon2i},
+		{"{ARGON2ID}" + refArgon2id, FormatDovecot, refArgon2id},
+		{"{ARGON2I}" + refArgon2i, FormatDovecot, refArgon2i},
+	}
+	for i, v := range vectors {
+		f, native := DecodeFormat(v.encoded)
+		if f != v.format || native != v.native {
+			t.Errorf("%d: got %v %q  want %v %q", i, f, native, v.format, v.native)
+		}
+		ok, err := NewContext().VerifyEncoded(v.encoded, []byte("password"))
+		if !ok || err != nil {
+			t.Errorf("%d: VerifyEncoded = %v, %v  want true", i, ok, err)
+		}
+	}
+}
+
+func TestDecodeFormatDovecotMismatch(t *testing.T) {
+	for _, encoded := range []string{"{ARGON2I}" + refArgon2id, "{ARGON2ID}" + refArgon2i, "{SSHA}" + refArgon2id} {
+		if f, native := DecodeFormat(encoded); f != FormatNative || native != encoded {
+			t.Errorf("%s: got %v %q", encoded, f, native)
+		}
+		ok, err := NewContext().VerifyEncoded(encoded, []byte("password"))
+		if ok || err != ErrEncodedFormat {
+			t.Errorf("%s: got %v, %v  want %v", encoded, ok, err, ErrEncodedFormat)
+		}
+	}
+}
+
+func TestCrossLibraryVectors(t *testing.T) {
+	for _, v := range crossLibraryVectors {
+		if f, _ := DecodeFormat(v.encoded); f != v.format {
+			t.Errorf("%s %s: format %v  want %v", v.library, v.encoded, f, v.format)
+		}
+		ok, err := NewContext().VerifyEncoded(v.encoded, []byte(v.password))
+		if !ok || err != nil {
+			t.Errorf("%s %s: got %v, %v  want true", v.library, v.encoded, ok, err)
+		}
+		ok, err = NewContext().VerifyEncoded(v.encoded, []byte("wrong"))
+		if ok || err != nil {
+			t.Errorf("%s %s wrong password: got %v, %v  want false", v.library, v.encoded, ok, err)
+		}
+		ok, replacement, err := NewMultiVerifier(NewContext()).Verify(v.encoded, []byte(v.password))
+		if !ok || replacement != "" || err != nil {
+			t.Errorf("%s %s MultiVerifier: got %v, %q, %v  want true", v.library, v.encoded, ok, replacement, err)
+		}
+
+		// re-encoding for the same library gives back its string, without the padding of a C buffer
+		ctx := NewContext()
+		hash, salt, err := ctx.SetFromEncoded(v.encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := EncodeFormat(v.format, ctx.encode(salt, hash))
+		if err != nil || s != strings.TrimRight(v.encoded, "\x00") {
+			t.Errorf("%s: EncodeFormat = %q, %v  want %q", v.library, s, err, v.encoded)
+		}
+	}
+}
+
+func TestHashEncodedFormat(t *testing.T) {
+	ctx := NewContext(ModeArgon2i).SetIterations(2).SetParallelism(1)
+	vectors := []struct {
+		format   Format
+		expected string
+	}{
+		{FormatNative, refArgon2i},
+		{FormatSodium, refArgon2i},
+		{FormatDjango, "argon2" + refArgon2i},
+		{FormatLDAP, "{ARGON2}" + refArgon2i},
+		{FormatDovecot, "{ARGON2I}" + refArgon2i},
+	}
+	for _, v := range vectors {
+		s, err := ctx.HashEncodedFormat(v.format, []byte("password"), []byte("somesalt"))
+		if err != nil {
+			t.Fatalf("%v: %v", v.format, err)
+		}
+		if s != v.expected {
+			t.Errorf("%v: got %q  want %q", v.format, s, v.expected)
+		}
+	}
+
+	_, err := EncodeFormat(FormatSodium, strings.Replace(refArgon2i, "argon2i", "argon2d", 1))
+	if err != ErrFormatParams {
+		t.Errorf("argon2d for sodium: got %v  want %v", err, ErrFormatParams)
+	}
+
+	_, err = EncodeFormat(FormatDovecot, strings.Replace(refArgon2i, "argon2i", "argon2d", 1))
+	if err != ErrFormatParams {
+		t.Errorf("argon2d for dovecot: got %v  want %v", err, ErrFormatParams)
+	}
+	s, err := EncodeFormat(FormatDovecot, refArgon2id)
+	if err != nil || s != "{ARGON2ID}"+refArgon2id {
+		t.Errorf("dovecot argon2id: got %q, %v", s, err)
+	}
+
+	ctx.SetSecret([]byte("somesecret"))
+	_, err = ctx.HashEncodedFormat(FormatLDAP, []byte("password"), []byte("somesalt"))
+	if err != ErrFormatSecret {
+		t.Errorf("secret for ldap: got %v  want %v", err, ErrFormatSecret)
+	}
+	_, err = ctx.HashEncodedFormat(FormatDjango, []byte("password"), []byte("somesalt"))
+	if err != ErrFormatSecret {
+		t.Errorf("secret: got %v  want %v", err, ErrFormatSecret)
+	}
+	_, err = EncodeFormat(FormatNative, strings.Replace(refArgon2i, "p=1", "p=1,keyid=AAAAAA", 1))
+	if err != ErrFormatSecret {
+		t.Errorf("keyid: got %v  want %v", err, ErrFormatSecret)
+	}
+}
@@ -0,0 +1,51 @@
+package argon2_go_withsecret
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+)
+
+// adMarkerData marks encodings hashed with caller supplied associated data.
+const adMarkerData = "ad"
+
+// secretFingerprintLen is deliberately short: enough to tell secrets apart, too short to confirm a guessed secret.
+const secretFingerprintLen = 4
+
+var (
+	ErrWrongSecret           = errors.New("argon2-go-withsecret: secret does not match the one used for the encoded hash")
+	ErrMissingAssociatedData = errors.New("argon2-go-withsecret: encoded hash requires associated data")
+)
+
+// sets Context fields. When on, HashEncoded records a short fingerprint of the secret as keyid= and
+// marks the use of associated data with ad=. The marker is a parameter of this package: the data= parameter
+// of libargon2 carries the associated data itself, so other implementations would hash differently if it were used.
+// VerifyEncoded can then tell a wrong secret or missing associated data apart from a wrong password.
+// The fingerprint is not reversible but secrets should still be high entropy random values.
+func (ctx *Context) SetEncodeHints(on bool) *Context {
+	ctx.encodeHints = on
+	return ctx
+}
+
+// secretFingerprint returns a short, domain separated digest of secret.
+func secretFingerprint(secret []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("argon2-go-withsecret keyid\x00"))
+	h.Write(secret)
+	return h.Sum(nil)[:secretFingerprintLen]
+}
+
+// checkHints compares the keyid= and ad= parameters of the last encoding with the Context,
+// after choosing the secret from the key ring if there is one.
+func (ctx *Context) checkHints() error {
+	ctx.selectSecret()
+	if ctx.keyID != nil {
+		if len(ctx.Secret) == 0 || subtle.ConstantTimeCompare(ctx.keyID, secretFingerprint(ctx.Secret)) != 1 {
+			return ErrWrongSecret
+		}
+	}
+	if ctx.adMarker != "" && len(ctx.AssociatedData) == 0 {
+		return ErrMissingAssociatedData
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package argon2_go_withsecret
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeHints(t *testing.T) {
+	ctx := NewContext().SetMemory(1 << 10).SetSecret([]byte("somesecret")).SetEncodeHints(true)
+	ctx.SetAssociatedData([]byte("somedata"))
+	s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s, ",keyid=") || !strings.Contains(s, ",ad=") {
+		t.Fatalf("hints missing from %s", s)
+	}
+	if strings.Contains(s, "somesecret") {
+		t.Fatalf("secret leaked into %s", s)
+	}
+
+	vectors := []struct {
+		secret []byte
+		ad     []byte
+		pw     string
+		ok     bool
+		err    error
+	}{
+		{[]byte("somesecret"), []byte("somedata"), "password", true, nil},
+		{[]byte("somesecret"), []byte("somedata"), "wrongpassword", false, nil},
+		{[]byte("othersecret"), []byte("somedata"), "password", false, ErrWrongSecret},
+		{nil, []byte("somedata"), "password", false, ErrWrongSecret},
+		{[]byte("somesecret"), nil, "password", false, ErrMissingAssociatedData},
+	}
+	for i, v := range vectors {
+		ctx4v := NewContext().SetSecret(v.secret).SetAssociatedData(v.ad)
+		ok, err := ctx4v.VerifyEncoded(s, []byte(v.pw))
+		if ok != v.ok || err != v.err {
+			t.Errorf("%d: got %v, %v  want %v, %v", i, ok, err, v.ok, v.err)
+		}
+	}
+}
+
+func TestEncodeHintsOff(t *testing.T) {
+	ctx := NewContext().SetMemory(1 << 10).SetSecret([]byte("somesecret"))
+	s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(s, "$") != 5 || strings.Contains(s, "keyid") {
+		t.Fatalf("unexpected hints in %s", s)
+	}
+	ok, err := NewContext().SetSecret([]byte("othersecret")).VerifyEncoded(s, []byte("password"))
+	if ok || err != nil {
+		t.Errorf("got %v, %v  want false, nil", ok, err)
+	}
+}
+
+func TestEncodeHintsNotLibargon2Data(t *testing.T) {
+	// libargon2 writes the associated data itself as data=, which this package does not mistake for its marker
+	s := strings.Replace(refArgon2id, "p=1$", "p=1,data=c29tZWRhdGE$", 1)
+	_, _, err := NewContext().SetFromEncoded(s)
+	if err != ErrEncodedFormatBadParameter {
+		t.Errorf("got %v  want %v", err, ErrEncodedFormatBadParameter)
+	}
+}
@@ -0,0 +1,279 @@
+// Package htpasswd reads and writes htpasswd files holding argon2_go_withsecret encodings
+// and protects net/http handlers with Basic Auth verified against them.
+//
+// Lines are user:encoded. Argon2 encodings in any form accepted by VerifyEncoded are verified with the
+// Context given to Open, legacy bcrypt lines ($2y$, as written by htpasswd -B) through a MultiVerifier.
+// Both are throttled by the package mutex and refused beyond the verify limits of the Context.
+// Blank lines and # comments are kept when the file is saved.
+package htpasswd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/learnfromgirls/argon2-go-withsecret"
+)
+
+var (
+	ErrMalformed = errors.New("htpasswd: malformed line")
+	ErrUser      = errors.New("htpasswd: user name is empty or contains ':'")
+)
+
+// CheckInterval is how often, at most, the file is checked for changes before a verification.
+var CheckInterval = time.Second
+
+// File is an htpasswd file that reloads itself when it changes on disk.
+type File struct {
+	path string
+	ctx  *argon2_go_withsecret.Context // template, cloned for every operation
+	mv   *argon2_go_withsecret.MultiVerifier
+
+	mu      sync.RWMutex
+	lines   []string       // as read, including comments
+	index   map[string]int // user to line
+	modTime time.Time
+	size    int64
+	checked time.Time
+	dirty   bool // Set or Delete since the last load or Save
+}
+
+// Open loads the htpasswd file at path. A missing file is treated as empty and created by Save.
+// ctx, including its secret, is used to verify and to hash new passwords; it is never mutated.
+func Open(path string, ctx *argon2_go_withsecret.Context) (*File, error) {
+	f := &File{
+		path: path,
+		ctx:  ctx,
+		mv:   argon2_go_withsecret.NewMultiVerifier(ctx),
+	}
+	err := f.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload reads the file again, discarding unsaved changes.
+func (f *File) Reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.load()
+}
+
+func (f *File) load() error {
+	f.checked = time.Now()
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		f.lines, f.index, f.modTime, f.size, f.dirty = nil, map[string]int{}, time.Time{}, 0, false
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	lines, index, err := parse(data)
+	if err != nil {
+		return err
+	}
+	f.lines, f.index, f.modTime, f.size, f.dirty = lines, index, fi.ModTime(), fi.Size(), false
+	return nil
+}
+
+func parse(data []byte) ([]string, map[string]int, error) {
+	var lines []string
+	index := map[string]int{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), "\r")
+		lines = append(lines, line)
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		user, encoded, found := strings.Cut(trimmed, ":")
+		if !found || user == "" || encoded == "" {
+			return nil, nil, fmt.Errorf("%w %d", ErrMalformed, n)
+		}
+		index[user] = len(lines) - 1
+	}
+	return lines, index, sc.Err()
+}
+
+// reloadIfChanged reloads the file if its size or modification time changed, checking at most every CheckInterval.
+// Unsaved changes are never discarded.
+func (f *File) reloadIfChanged() error {
+	f.mu.RLock()
+	due := time.Since(f.checked) >= CheckInterval && !f.dirty
+	f.mu.RUnlock()
+	if !due {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dirty {
+		return nil
+	}
+	f.checked = time.Now()
+	fi, err := os.Stat(f.path)
+	if os.IsNotExist(err) && f.lines == nil {
+		return nil
+	}
+	if err == nil && fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return nil
+	}
+	return f.load()
+}
+
+// lookup returns the stored encoding of user.
+func (f *File) lookup(user string) (string, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	i, found := f.index[user]
+	if !found {
+		return "", false
+	}
+	_, encoded, _ := strings.Cut(strings.TrimSpace(f.lines[i]), ":")
+	return encoded, true
+}
+
+// Users returns the user names in file order.
+func (f *File) Users() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	users := make([]string, 0, len(f.index))
+	for i, line := range f.lines {
+		user, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+		if j, found := f.index[user]; found && j == i {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+// Verify checks password for user. An unknown user costs the same as a wrong password of an Argon2 line hashed with
+// the Context parameters, see VerifyMissingUser. bcrypt lines cost what their cost factor says, so in a file that is
+// still mostly bcrypt the time taken can tell an unknown user from a known one.
+func (f *File) Verify(user string, password []byte) (bool, error) {
+	err := f.reloadIfChanged()
+	if err != nil {
+		return false, err
+	}
+	encoded, found := f.lookup(user)
+	if !found {
+		return f.ctx.Clone().VerifyMissingUser(password)
+	}
+	ok, _, err := f.mv.Verify(encoded, password)
+	return ok, err
+}
+
+// Set hashes password for user with a new salt from the Context salt generator, adding the user if needed. Call Save to write the file.
+func (f *File) Set(user string, password []byte) error {
+	if user == "" || strings.ContainsAny(user, ":\r\n") {
+		return ErrUser
+	}
+	encoded, err := f.ctx.Clone().HashEncodedRandomSalt(password)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	line := user + ":" + encoded
+	if i, found := f.index[user]; found {
+		f.lines[i] = line
+	} else {
+		f.lines = append(f.lines, line)
+		f.index[user] = len(f.lines) - 1
+	}
+	f.dirty = true
+	return nil
+}
+
+// Delete removes every line of user. Call Save to write the file.
+func (f *File) Delete(user string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, found := f.index[user]; !found {
+		return
+	}
+	lines := f.lines[:0]
+	for _, line := range f.lines {
+		if u, _, _ := strings.Cut(strings.TrimSpace(line), ":"); u == user {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	f.lines = lines
+	delete(f.index, user)
+	f.dirty = true
+	for i, line := range f.lines {
+		u, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+		if _, found := f.index[u]; found {
+			f.index[u] = i
+		}
+	}
+}
+
+// Save writes the file atomically with mode 0600.
+func (f *File) Save() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var buf bytes.Buffer
+	for _, line := range f.lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".htpasswd-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(buf.Bytes()); err == nil {
+		err = tmp.Chmod(0600)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp.Name(), f.path)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	f.modTime, f.size, f.checked, f.dirty = fi.ModTime(), fi.Size(), time.Now(), false
+	return nil
+}
+
+// Middleware returns a handler that requires Basic Auth credentials verified against the file before calling next.
+// Verification, of bcrypt lines too, goes through the package mutex, so concurrent logins are throttled, and unknown
+// users get a dummy verification so that response times do not reveal which users exist, as long as the file holds
+// Argon2 lines, see Verify.
+func (f *File) Middleware(realm string, next http.Handler) http.Handler {
+	challenge := fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", realm)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if ok {
+			ok, _ = f.Verify(user, []byte(password))
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", challenge)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,232 @@
+package htpasswd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/learnfromgirls/argon2-go-withsecret"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newContext() *argon2_go_withsecret.Context {
+	return argon2_go_withsecret.NewContext().SetMemory(1 << 10).SetSecret([]byte("somesecret"))
+}
+
+// noCheckInterval makes every verification look for changes to the file until the test ends.
+func noCheckInterval(t *testing.T) {
+	interval := CheckInterval
+	CheckInterval = 0
+	t.Cleanup(func() { CheckInterval = interval })
+}
+
+func TestFile(t *testing.T) {
+	noCheckInterval(t)
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	bc, err := bcrypt.GenerateFromPassword([]byte("legacy"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, []byte("# admins\nbob:"+string(bc)+"\n\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(path, newContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Set("alice", []byte("password")); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	vectors := []struct {
+		user, password string
+		ok             bool
+	}{
+		{"alice", "password", true},
+		{"alice", "wrong", false},
+		{"bob", "legacy", true},
+		{"bob", "wrong", false},
+		{"carol", "password", false},
+	}
+	for _, v := range vectors {
+		ok, err := f.Verify(v.user, []byte(v.password))
+		if ok != v.ok || err != nil {
+			t.Errorf("Verify(%s, %s) = %v, %v  want %v", v.user, v.password, ok, err, v.ok)
+		}
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "# admins\nbob:$2a$") || !strings.Contains(string(data), "\nalice:$argon2id$") {
+		t.Errorf("unexpected file contents:\n%s", data)
+	}
+
+	// another process replaces the file
+	other, err := Open(path, newContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Delete("bob")
+	if err = other.Set("carol", []byte("password")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err = other.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := f.Verify("carol", []byte("password")); !ok {
+		t.Errorf("file not reloaded after change")
+	}
+	if got := f.Users(); !reflect.DeepEqual(got, []string{"alice", "carol"}) {
+		t.Errorf("Users() = %v", got)
+	}
+}
+
+func TestDeleteDuplicate(t *testing.T) {
+	noCheckInterval(t)
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	f, err := Open(path, newContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"bob", "alice"} {
+		if err = f.Set(user, []byte("password")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	bob, _, _ := strings.Cut(string(data), "\n")
+	// a second bob line, as left by hand editing
+	if err = os.WriteFile(path, append(data, bob+"\n"...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Delete("bob")
+	if err = f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := f.Verify("bob", []byte("password")); ok {
+		t.Errorf("deleted user came back")
+	}
+	if ok, err := f.Verify("alice", []byte("password")); !ok || err != nil {
+		t.Errorf("alice: got %v, %v", ok, err)
+	}
+	if got := f.Users(); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Errorf("Users() = %v", got)
+	}
+}
+
+func TestPrefixedLines(t *testing.T) {
+	// other libraries cannot carry a secret
+	ctx := argon2_go_withsecret.NewContext().SetMemory(1 << 10)
+	var data []byte
+	formats := []argon2_go_withsecret.Format{argon2_go_withsecret.FormatDjango, argon2_go_withsecret.FormatLDAP, argon2_go_withsecret.FormatDovecot}
+	for _, format := range formats {
+		s, err := ctx.HashEncodedFormat(format, []byte("password"), []byte("somesalt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, format.String()+":"+s+"\n"...)
+	}
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(path, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range formats {
+		if ok, err := f.Verify(format.String(), []byte("password")); !ok || err != nil {
+			t.Errorf("%v: got %v, %v  want true", format, ok, err)
+		}
+		if ok, err := f.Verify(format.String(), []byte("wrong")); ok || err != nil {
+			t.Errorf("%v wrong password: got %v, %v  want false", format, ok, err)
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	os.WriteFile(path, []byte("alice:$argon2id$x\nnocolon\n"), 0600)
+	_, err := Open(path, newContext())
+	if err == nil || !strings.Contains(err.Error(), "malformed line 2") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestVerifyLimits(t *testing.T) {
+	bc, err := bcrypt.GenerateFromPassword([]byte("legacy"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	err = os.WriteFile(path, []byte("bob:"+strings.Replace(string(bc), "$04$", "$31$", 1)+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(path, newContext().SetVerifyLimits(1<<16, 3, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := f.Verify("bob", []byte("legacy"))
+	if ok || err != argon2_go_withsecret.ErrVerifyLimit {
+		t.Errorf("got %v, %v  want %v", ok, err, argon2_go_withsecret.ErrVerifyLimit)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	f, err := Open(filepath.Join(t.TempDir(), "htpasswd"), newContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Set("alice", []byte("password")); err != nil {
+		t.Fatal(err)
+	}
+	h := f.Middleware("tools", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	vectors := []struct {
+		user, password string
+		auth           bool
+		code           int
+	}{
+		{"alice", "password", true, http.StatusOK},
+		{"alice", "wrong", true, http.StatusUnauthorized},
+		{"mallory", "password", true, http.StatusUnauthorized},
+		{"", "", false, http.StatusUnauthorized},
+	}
+	for _, v := range vectors {
+		r := httptest.NewRequest("GET", "/", nil)
+		if v.auth {
+			r.SetBasicAuth(v.user, v.password)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != v.code {
+			t.Errorf("%s/%s: got %d  want %d", v.user, v.password, w.Code, v.code)
+		}
+		if w.Code == http.StatusUnauthorized && !strings.Contains(w.Header().Get("WWW-Authenticate"), `realm="tools"`) {
+			t.Errorf("missing challenge: %v", w.Header())
+		}
+	}
+}
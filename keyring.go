@@ -0,0 +1,124 @@
+package argon2_go_withsecret
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keyRingMinSecret is the shortest secret accepted in a key ring.
+const keyRingMinSecret = 16
+
+var (
+	ErrKeyRingEmpty  = errors.New("argon2-go-withsecret: key ring has no secrets")
+	ErrKeyRingFormat = errors.New("argon2-go-withsecret: malformed key ring")
+)
+
+// KeyRing holds the current secret and the retired secrets still needed to verify older hashes.
+// Secrets are told apart by the keyid= fingerprint that SetEncodeHints records, see SetKeyRing.
+type KeyRing struct {
+	secrets [][]byte // current first
+}
+
+// NewKeyRing returns a key ring whose first secret is current.
+// Secrets must be at least 16 bytes and have distinct fingerprints.
+func NewKeyRing(secrets ...[]byte) (*KeyRing, error) {
+	if len(secrets) == 0 {
+		return nil, ErrKeyRingEmpty
+	}
+	seen := map[string]bool{}
+	for i, s := range secrets {
+		fp := string(secretFingerprint(s))
+		if len(s) < keyRingMinSecret || seen[fp] {
+			return nil, fmt.Errorf("%w: secret %d is too short or a duplicate", ErrKeyRingFormat, i+1)
+		}
+		seen[fp] = true
+	}
+	return &KeyRing{secrets: secrets}, nil
+}
+
+// LoadKeyRing reads a key ring file holding one base64 secret per line, current first.
+// Blank lines and # comments are ignored. The file should be readable by the service only.
+func LoadKeyRing(path string) (*KeyRing, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var secrets [][]byte
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		secret, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(line, "="))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d is not base64", ErrKeyRingFormat, n)
+		}
+		secrets = append(secrets, secret)
+	}
+	if err = sc.Err(); err != nil {
+		return nil, err
+	}
+	return NewKeyRing(secrets...)
+}
+
+// Current returns the secret new hashes are made with.
+func (kr *KeyRing) Current() []byte {
+	return kr.secrets[0]
+}
+
+// lookup returns the secret with fingerprint keyID, or nil.
+func (kr *KeyRing) lookup(keyID []byte) []byte {
+	for _, s := range kr.secrets {
+		if subtle.ConstantTimeCompare(keyID, secretFingerprint(s)) == 1 {
+			return s
+		}
+	}
+	return nil
+}
+
+// sets Context fields. The current secret of kr is used for hashing, with SetEncodeHints on so that each encoding
+// records which secret made it. VerifyEncoded picks the secret named by keyid= from the ring, falling back to the
+// current secret for encodings without one, and NeedsRehash reports hashes made with a retired secret.
+// The current secret is set again when VerifyEncoded returns.
+func (ctx *Context) SetKeyRing(kr *KeyRing) *Context {
+	ctx.keyRing = kr
+	ctx.SetSecret(kr.Current())
+	return ctx.SetEncodeHints(true)
+}
+
+// selectSecret sets the secret for the keyid= of the last encoding from the key ring, if there is one,
+// until restoreSecret.
+func (ctx *Context) selectSecret() {
+	ctx.retired = false
+	if ctx.keyRing == nil {
+		return
+	}
+	secret := ctx.keyRing.Current()
+	if ctx.keyID != nil {
+		if s := ctx.keyRing.lookup(ctx.keyID); s != nil {
+			secret = s
+		}
+	}
+	ctx.retired = !bytes.Equal(secret, ctx.keyRing.Current())
+	ctx.SetSecret(secret)
+}
+
+// restoreSecret sets the current secret of the key ring again after a verification, so that a retired secret
+// selected for it is never used to hash.
+func (ctx *Context) restoreSecret() {
+	if ctx.keyRing != nil {
+		ctx.SetSecret(ctx.keyRing.Current())
+	}
+}
+
+// retiredSecret reports whether the last encoding was verified with a retired secret of the key ring.
+func (ctx *Context) retiredSecret() bool {
+	return ctx.retired
+}
@@ -0,0 +1,87 @@
+package argon2_go_withsecret
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyRingRotation(t *testing.T) {
+	oldSecret := bytes.Repeat([]byte("o"), 32)
+	newSecret := bytes.Repeat([]byte("n"), 32)
+	oldRing, err := NewKeyRing(oldSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext().SetMemory(1 << 10).SetKeyRing(oldRing)
+	s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err := NewKeyRing(newSecret, oldSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx4v := NewContext().SetKeyRing(ring)
+	ok, err := ctx4v.VerifyEncoded(s, []byte("password"))
+	if !ok || err != nil {
+		t.Fatalf("retired secret: got %v, %v  want true", ok, err)
+	}
+	if !ctx4v.NeedsRehash() {
+		t.Error("hash made with a retired secret does not need rehash")
+	}
+	if !bytes.Equal(ctx4v.Secret, newSecret) {
+		t.Error("retired secret left on the Context after VerifyEncoded")
+	}
+
+	s, err = NewContext().SetMemory(1<<10).SetKeyRing(ring).HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err = ctx4v.VerifyEncoded(s, []byte("password"))
+	if !ok || err != nil || ctx4v.NeedsRehash() {
+		t.Errorf("current secret: got %v, %v, rehash %v  want true, nil, false", ok, err, ctx4v.NeedsRehash())
+	}
+
+	// a secret that was dropped from the ring is reported as such
+	newRing, _ := NewKeyRing(newSecret)
+	_, err = NewContext().SetKeyRing(newRing).VerifyEncoded(
+		mustHashEncoded(t, NewContext().SetMemory(1<<10).SetKeyRing(oldRing)), []byte("password"))
+	if err != ErrWrongSecret {
+		t.Errorf("dropped secret: got %v  want %v", err, ErrWrongSecret)
+	}
+}
+
+func mustHashEncoded(t *testing.T, ctx *Context) string {
+	s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring")
+	data := "# current\nbmV3bmV3bmV3bmV3bmV3bmV3bmV3bmV3\n\nb2xkb2xkb2xkb2xkb2xkb2xk==\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	kr, err := LoadKeyRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(kr.Current()) != "newnewnewnewnewnewnewnew" || len(kr.secrets) != 2 {
+		t.Errorf("got %q, %d secrets", kr.Current(), len(kr.secrets))
+	}
+
+	for _, bad := range []string{"", "# none\n", "not base64!\n", "c2hvcnQ\n"} {
+		os.WriteFile(path, []byte(bad), 0600)
+		_, err = LoadKeyRing(path)
+		if !errors.Is(err, ErrKeyRingFormat) && err != ErrKeyRingEmpty {
+			t.Errorf("%q: got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package argon2_go_withsecret
+
+/*
+#cgo LDFLAGS: -largon2
+#include <stdlib.h>
+#include <string.h>
+#include <argon2.h>
+
+// The memory pool keeps the working memory of one hash for the next.
+// Every hash runs under the package mutex, so the block is never lent twice.
+static uint8_t *pool_block;
+static size_t pool_size;
+static unsigned long long pool_allocs, pool_reuses;
+
+// pool_reserve makes the block at least bytes long, touching every page of a new block if prefault is set.
+static int pool_reserve(size_t bytes, int prefault) {
+	if (pool_block != NULL && pool_size >= bytes) {
+		return ARGON2_OK;
+	}
+	free(pool_block);
+	pool_size = 0;
+	pool_block = malloc(bytes);
+	if (pool_block == NULL) {
+		return ARGON2_MEMORY_ALLOCATION_ERROR;
+	}
+	pool_size = bytes;
+	pool_allocs++;
+	if (prefault) {
+		memset(pool_block, 0, bytes);
+	}
+	return ARGON2_OK;
+}
+
+static int pool_allocate(uint8_t **memory, size_t bytes) {
+	if (pool_block != NULL && pool_size >= bytes) {
+		pool_reuses++;
+	}
+	int rc = pool_reserve(bytes, 0);
+	if (rc == ARGON2_OK) {
+		*memory = pool_block;
+	}
+	return rc;
+}
+
+// libargon2 wipes the memory before handing it back, so it is only kept.
+static void pool_free(uint8_t *memory, size_t bytes) {
+}
+
+static void pool_counts(unsigned long long *allocs, unsigned long long *reuses) {
+	*allocs = pool_allocs;
+	*reuses = pool_reuses;
+}
+
+static int pool_wiped(void) {
+	for (size_t i = 0; i < pool_size; i++) {
+		if (pool_block[i] != 0) {
+			return 0;
+		}
+	}
+	return 1;
+}
+
+static void pool_release(void) {
+	free(pool_block);
+	pool_block = NULL;
+	pool_size = 0;
+}
+
+// argon2go_ctx builds the argon2_context here so that no Go pointer is stored in C memory.
+static int argon2go_ctx(argon2_type type, uint8_t *out, uint32_t outlen, uint8_t *pwd, uint32_t pwdlen,
+		uint8_t *salt, uint32_t saltlen, uint8_t *secret, uint32_t secretlen, uint8_t *ad, uint32_t adlen,
+		uint32_t t_cost, uint32_t m_cost, uint32_t lanes, uint32_t threads, uint32_t version, uint32_t flags,
+		int pooled) {
+	argon2_context ctx = {
+		.out = out, .outlen = outlen,
+		.pwd = pwd, .pwdlen = pwdlen,
+		.salt = salt, .saltlen = saltlen,
+		.secret = secret, .secretlen = secretlen,
+		.ad = ad, .adlen = adlen,
+		.t_cost = t_cost, .m_cost = m_cost,
+		.lanes = lanes, .threads = threads,
+		.version = version,
+		.allocate_cbk = NULL, .free_cbk = NULL,
+		.flags = flags,
+	};
+	if (pooled) {
+		ctx.allocate_cbk = pool_allocate;
+		ctx.free_cbk = pool_free;
+	}
+	return argon2_ctx(&ctx, type);
+}
+*/
+import "C"
+
+import (
+	"crypto/subtle"
+	"unsafe"
+
+	"github.com/tvdburgt/go-argon2"
+)
+
+// memoryPool is set by SetMemoryPool and read under the package mutex.
+var memoryPool bool
+
+// SetMemoryPool makes hashes reuse one block of working memory instead of having libargon2 map and unmap
+// m= KiB for every hash, which under load means a storm of page faults and RSS spikes.
+// The block grows to the largest memory hashed so far and stays allocated until SetMemoryPool(false).
+// libargon2 wipes it at the end of every hash, before it is reused.
+func SetMemoryPool(on bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	memoryPool = on
+	if !on {
+		C.pool_release()
+	}
+}
+
+// PrefaultMemoryPool turns the memory pool on and allocates its block for memory KiB up front,
+// touching every page so that even the first hash does not fault it in.
+func PrefaultMemoryPool(memory int) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	memoryPool = true
+	rc := C.pool_reserve(C.size_t(memory)<<10, 1)
+	if rc != C.ARGON2_OK {
+		return argon2.Error(rc)
+	}
+	return nil
+}
+
+// memoryPoolCounts returns how often the pool allocated a block and how often a hash reused one.
+func memoryPoolCounts() (allocs uint64, reuses uint64) {
+	var a, r C.ulonglong
+	mutex.Lock()
+	C.pool_counts(&a, &r)
+	mutex.Unlock()
+	return uint64(a), uint64(r)
+}
+
+// memoryPoolWiped reports whether the pool block holds only zeros.
+func memoryPoolWiped() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return C.pool_wiped() == 1
+}
+
+// hashLibargon2 is argon2.Hash calling libargon2 directly, for the thread count and memory pool that
+// go-argon2 does not expose. threads 0 means one per lane. The caller holds the package mutex.
+func hashLibargon2(c *argon2.Context, threads int, pooled bool, password []byte, salt []byte) ([]byte, error) {
+	if c == nil {
+		return nil, ErrContext
+	}
+	if len(password) == 0 {
+		return nil, ErrPassword
+	}
+	if len(salt) == 0 {
+		return nil, ErrSalt
+	}
+	if threads <= 0 {
+		threads = c.Parallelism
+	}
+	if c.HashLen < 1 {
+		return nil, argon2.ErrOutputTooShort
+	}
+	hash := make([]byte, c.HashLen)
+	rc := C.argon2go_ctx(C.argon2_type(c.Mode),
+		bytesPtr(hash), C.uint32_t(len(hash)),
+		bytesPtr(password), C.uint32_t(len(password)),
+		bytesPtr(salt), C.uint32_t(len(salt)),
+		bytesPtr(c.Secret), C.uint32_t(len(c.Secret)),
+		bytesPtr(c.AssociatedData), C.uint32_t(len(c.AssociatedData)),
+		C.uint32_t(c.Iterations), C.uint32_t(c.Memory),
+		C.uint32_t(c.Parallelism), C.uint32_t(threads),
+		C.uint32_t(c.Version), C.uint32_t(c.Flags), cBool(pooled))
+	if rc != C.ARGON2_OK {
+		return nil, argon2.Error(rc)
+	}
+	return hash, nil
+}
+
+// verifyLibargon2 is argon2.Verify using hashLibargon2.
+func verifyLibargon2(c *argon2.Context, threads int, pooled bool, hash []byte, password []byte, salt []byte) (bool, error) {
+	if len(hash) == 0 {
+		return false, ErrHash
+	}
+	if c == nil {
+		return false, ErrContext
+	}
+	c.HashLen = len(hash)
+	h, err := hashLibargon2(c, threads, pooled, password, salt)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(h, hash) == 1, nil
+}
+
+func bytesPtr(b []byte) *C.uint8_t {
+	if len(b) == 0 {
+		return nil
+	}
+	return (*C.uint8_t)(unsafe.Pointer(&b[0]))
+}
+
+func cBool(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
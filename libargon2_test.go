@@ -0,0 +1,76 @@
+package argon2_go_withsecret
+
+import (
+	"bytes"
+	"testing"
+)
+
+// withMemoryPool turns the memory pool on until the test ends.
+func withMemoryPool(t *testing.T) {
+	SetMemoryPool(true)
+	t.Cleanup(func() { SetMemoryPool(false) })
+}
+
+func TestMemoryPool(t *testing.T) {
+	withMemoryPool(t)
+	allocs, reuses := memoryPoolCounts()
+	for _, v := range []struct {
+		mode    int
+		encoded string
+	}{
+		{ModeArgon2i, refArgon2i},
+		{ModeArgon2id, refArgon2id},
+	} {
+		ctx := NewContext(v.mode).SetIterations(2).SetParallelism(1)
+		s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != v.encoded {
+			t.Errorf("got %q  want %q", s, v.encoded)
+		}
+		ok, err := NewContext().VerifyEncoded(v.encoded, []byte("password"))
+		if !ok || err != nil {
+			t.Errorf("VerifyEncoded = %v, %v  want true", ok, err)
+		}
+	}
+	a, r := memoryPoolCounts()
+	if a-allocs != 1 || r-reuses != 3 {
+		t.Errorf("got %d allocations and %d reuses  want 1 and 3", a-allocs, r-reuses)
+	}
+	if !memoryPoolWiped() {
+		t.Error("working memory is not wiped between hashes")
+	}
+
+	// a larger hash grows the block
+	if _, err := NewContext().SetMemory(1<<17).Hash([]byte("password"), []byte("somesalt")); err != nil {
+		t.Fatal(err)
+	}
+	if a2, _ := memoryPoolCounts(); a2 != a+1 {
+		t.Errorf("got %d allocations  want %d", a2-allocs, a+1-allocs)
+	}
+
+	_, err := NewContext().Hash([]byte("password"), []byte("s"))
+	if !ErrSaltTooShort.Equals(err) {
+		t.Errorf("got %q  want %q", err, ErrSaltTooShort)
+	}
+	password := []byte("somepassword")
+	NewContext().SetMemory(1<<10).SetFlags(FlagClearPassword).Hash(password, []byte("somesalt"))
+	if !bytes.Equal(make([]byte, len(password)), password) {
+		t.Error("password slice is not cleared")
+	}
+}
+
+func TestPrefaultMemoryPool(t *testing.T) {
+	withMemoryPool(t)
+	if err := PrefaultMemoryPool(1 << 16); err != nil {
+		t.Fatal(err)
+	}
+	allocs, reuses := memoryPoolCounts()
+	if _, err := NewContext().Hash([]byte("password"), []byte("somesalt")); err != nil {
+		t.Fatal(err)
+	}
+	if a, r := memoryPoolCounts(); a != allocs || r != reuses+1 {
+		t.Errorf("got %d allocations and %d reuses after prefaulting  want 0 and 1", a-allocs, r-reuses)
+	}
+}
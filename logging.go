@@ -0,0 +1,143 @@
+package argon2_go_withsecret
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redacted = "REDACTED"
+
+var (
+	loggerMutex   sync.RWMutex
+	defaultLogger *slog.Logger
+	slowThreshold time.Duration
+)
+
+// SetDefaultLogger sets the slog.Logger used by every Context that has no logger of its own.
+// nil (the default) disables logging.
+func SetDefaultLogger(l *slog.Logger) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+	defaultLogger = l
+}
+
+// SetSlowThreshold makes hashes whose compute time exceeds d be logged at Warn level.
+// Zero (the default) disables slow hash logging.
+func SetSlowThreshold(d time.Duration) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+	slowThreshold = d
+}
+
+// sets Context fields. Overrides the default logger for this Context only.
+func (ctx *Context) SetLogger(l *slog.Logger) *Context {
+	ctx.logger = l
+	return ctx
+}
+
+func (ctx *Context) getLogger() *slog.Logger {
+	if ctx.logger != nil {
+		return ctx.logger
+	}
+	loggerMutex.RLock()
+	defer loggerMutex.RUnlock()
+	return defaultLogger
+}
+
+// logParseError logs why an encoded hash was rejected.
+// Only the algorithm identifier and number of parts are logged, never the salt or hash.
+func (ctx *Context) logParseError(encoded string, err error) {
+	l := ctx.getLogger()
+	if l == nil {
+		return
+	}
+	parts := strings.Split(encoded, "$")
+	var alg string
+	if len(parts) > 1 {
+		alg = parts[1]
+	}
+	l.LogAttrs(context.Background(), slog.LevelInfo, "argon2: cannot parse encoded hash",
+		slog.String("algorithm", alg),
+		slog.Int("parts", len(parts)),
+		slog.Any("error", err))
+}
+
+// logDecision logs a policy decision such as parameters taken from an encoding or a refused operation.
+func (ctx *Context) logDecision(level slog.Level, msg string, attrs ...slog.Attr) {
+	l := ctx.getLogger()
+	if l == nil {
+		return
+	}
+	attrs = append(attrs, slog.Any("context", ctx))
+	l.LogAttrs(context.Background(), level, "argon2: "+msg, attrs...)
+}
+
+// logIfSlow logs an observation whose compute time exceeded the slow threshold.
+func (ctx *Context) logIfSlow(o *Observation) {
+	loggerMutex.RLock()
+	threshold := slowThreshold
+	loggerMutex.RUnlock()
+	if threshold <= 0 || o.Compute <= threshold {
+		return
+	}
+	l := ctx.getLogger()
+	if l == nil {
+		return
+	}
+	l.LogAttrs(context.Background(), slog.LevelWarn, "argon2: slow hash",
+		slog.String("op", o.Op),
+		slog.Duration("queue_wait", o.QueueWait),
+		slog.Duration("compute", o.Compute),
+		slog.Duration("threshold", threshold),
+		slog.Any("context", ctx))
+}
+
+func redact(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return redacted
+}
+
+// LogValue implements slog.LogValuer. The secret and associated data are redacted.
+func (ctx *Context) LogValue() slog.Value {
+	if ctx == nil || ctx.a2ctx == nil {
+		return slog.StringValue("<nil>")
+	}
+	return slog.GroupValue(
+		slog.String("mode", argon2_type2string(ctx.a2ctx.Mode)),
+		slog.Int("version", ctx.a2ctx.Version),
+		slog.Int("memory", ctx.a2ctx.Memory),
+		slog.Int("iterations", ctx.a2ctx.Iterations),
+		slog.Int("parallelism", ctx.a2ctx.Parallelism),
+		slog.Int("hashlen", ctx.a2ctx.HashLen),
+		slog.Int("flags", ctx.Flags),
+		slog.String("secret", redact(ctx.Secret)),
+		slog.String("associateddata", redact(ctx.AssociatedData)))
+}
+
+// String describes the Context with the secret and associated data redacted.
+func (ctx *Context) String() string {
+	if ctx == nil || ctx.a2ctx == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("{mode=%s v=%d m=%d t=%d p=%d hashlen=%d flags=%d secret=%s associateddata=%s}",
+		argon2_type2string(ctx.a2ctx.Mode),
+		ctx.a2ctx.Version,
+		ctx.a2ctx.Memory,
+		ctx.a2ctx.Iterations,
+		ctx.a2ctx.Parallelism,
+		ctx.a2ctx.HashLen,
+		ctx.Flags,
+		redact(ctx.Secret),
+		redact(ctx.AssociatedData))
+}
+
+// GoString is used by %#v and redacts like String.
+func (ctx *Context) GoString() string {
+	return "&argon2_go_withsecret.Context" + ctx.String()
+}
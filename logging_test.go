@@ -0,0 +1,66 @@
+package argon2_go_withsecret
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContextRedaction(t *testing.T) {
+	ctx := NewContext()
+	ctx.SetSecret([]byte("topsecretvalue"))
+	ctx.SetAssociatedData([]byte("adadadadad"))
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("ctx", "ctx", ctx)
+
+	for _, s := range []string{
+		fmt.Sprintf("%v", ctx),
+		fmt.Sprintf("%+v", ctx),
+		fmt.Sprintf("%#v", ctx),
+		buf.String(),
+	} {
+		if strings.Contains(s, "topsecretvalue") || strings.Contains(s, "adadadadad") {
+			t.Errorf("secret or associated data leaked: %s", s)
+		}
+		if !strings.Contains(s, redacted) || !strings.Contains(s, "65536") {
+			t.Errorf("unexpected formatting: %s", s)
+		}
+	}
+}
+
+func TestLogParseError(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := NewContext().SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	_, err := ctx.VerifyEncoded("$argon2id$v=19$m=65536,t=3$c29tZXNhbHQ$aGFzaA", []byte("password"))
+	if err != ErrEncodedFormatNotThreeSubParts {
+		t.Fatalf("got %v want %v", err, ErrEncodedFormatNotThreeSubParts)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "algorithm=argon2id") || !strings.Contains(out, "Not 3 subparts") {
+		t.Errorf("parse error not logged: %s", out)
+	}
+	if strings.Contains(out, "aGFzaA") {
+		t.Errorf("hash logged: %s", out)
+	}
+}
+
+func TestLogSlowHash(t *testing.T) {
+	var buf bytes.Buffer
+	SetSlowThreshold(time.Nanosecond)
+	defer SetSlowThreshold(0)
+
+	ctx := NewContext().SetMemory(1 << 10).SetSecret([]byte("topsecretvalue"))
+	ctx.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	if _, err := ctx.Hash([]byte("password"), []byte("somesalt")); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "slow hash") || strings.Contains(out, "topsecretvalue") {
+		t.Errorf("unexpected slow hash log: %s", out)
+	}
+}
@@ -0,0 +1,121 @@
+package argon2_go_withsecret
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var ErrMemoryPreflight = errors.New("argon2-go-withsecret: not enough memory available for hash parameters")
+
+var (
+	preflightMutex  sync.RWMutex
+	preflightMargin float64
+)
+
+// memoryLimits is replaced in tests.
+var memoryLimits = detectMemoryLimits
+
+// memoryLimitsTTL is how long the preflight reuses the memory limits it read, so that a busy server does not read
+// /proc and the cgroup files for every hash. It is replaced in tests.
+var memoryLimitsTTL = 100 * time.Millisecond
+
+var (
+	limitsMutex sync.Mutex
+	limitsRead  time.Time // zero when the limits must be read again
+	limitsCache MemoryLimits
+	limitsErr   error
+)
+
+// MemoryLimits describes the memory available to the process, as far as it can be detected.
+// On Linux it is read from the cgroup v2 or v1 memory controller and /proc/meminfo; elsewhere it is empty.
+type MemoryLimits struct {
+	CgroupVersion int    // 1 or 2, 0 if no cgroup memory limit was found
+	CgroupLimit   uint64 // bytes, 0 if there is no limit
+	CgroupUsage   uint64 // bytes charged to the cgroup, less inactive page cache that can be reclaimed
+	Available     uint64 // MemAvailable of the system in bytes, 0 if unknown
+}
+
+// DetectMemoryLimits reads the current memory limits and usage.
+func DetectMemoryLimits() (MemoryLimits, error) {
+	return memoryLimits()
+}
+
+// Headroom returns how many more bytes the process can allocate before hitting the smallest known limit.
+// It returns false if no limit is known.
+func (l MemoryLimits) Headroom() (uint64, bool) {
+	var headroom uint64
+	known := false
+	if l.CgroupLimit > 0 {
+		headroom, known = 0, true
+		if l.CgroupLimit > l.CgroupUsage {
+			headroom = l.CgroupLimit - l.CgroupUsage
+		}
+	}
+	if l.Available > 0 && (!known || l.Available < headroom) {
+		headroom, known = l.Available, true
+	}
+	return headroom, known
+}
+
+// MaxMemory returns the largest SetMemory value, in KiB, that leaves the fraction margin of the headroom free.
+// It returns false if no limit is known.
+func (l MemoryLimits) MaxMemory(margin float64) (int, bool) {
+	headroom, known := l.Headroom()
+	if !known {
+		return 0, false
+	}
+	return int(float64(headroom) * (1 - margin) / 1024), true
+}
+
+// SetMemoryPreflight makes every hash first check the detected memory limits and fail with ErrMemoryPreflight,
+// instead of getting the process killed by the kernel, if its memory would leave less than the fraction margin
+// of the headroom free. For example 0.25 keeps a quarter of the headroom for the rest of the process.
+// Zero (the default) disables the preflight. Hashes are allowed when no limit can be detected.
+// The limits are read at most every 100ms, and again after SetMemoryPreflight.
+func SetMemoryPreflight(margin float64) {
+	preflightMutex.Lock()
+	defer preflightMutex.Unlock()
+	preflightMargin = margin
+	limitsMutex.Lock()
+	limitsRead = time.Time{}
+	limitsMutex.Unlock()
+}
+
+// cachedMemoryLimits returns the memory limits, read again if they are older than memoryLimitsTTL.
+func cachedMemoryLimits() (MemoryLimits, error) {
+	limitsMutex.Lock()
+	if !limitsRead.IsZero() && time.Since(limitsRead) < memoryLimitsTTL {
+		defer limitsMutex.Unlock()
+		return limitsCache, limitsErr
+	}
+	limitsMutex.Unlock()
+	l, err := memoryLimits()
+	limitsMutex.Lock()
+	limitsCache, limitsErr, limitsRead = l, err, time.Now()
+	limitsMutex.Unlock()
+	return l, err
+}
+
+// preflight checks the Context memory against the detected limits, see SetMemoryPreflight.
+// It is called before taking the package mutex.
+func (ctx *Context) preflight() error {
+	preflightMutex.RLock()
+	margin := preflightMargin
+	preflightMutex.RUnlock()
+	if margin <= 0 {
+		return nil
+	}
+	l, err := cachedMemoryLimits()
+	if err != nil {
+		return nil
+	}
+	max, known := l.MaxMemory(margin)
+	if !known || ctx.a2ctx.Memory <= max {
+		return nil
+	}
+	ctx.logDecision(slog.LevelWarn, "refusing hash beyond memory limits",
+		slog.Int("max_memory", max), slog.Any("limits", l))
+	return ErrMemoryPreflight
+}
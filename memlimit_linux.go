@@ -0,0 +1,126 @@
+package argon2_go_withsecret
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procRoot and cgroupRoot are replaced in tests.
+var (
+	procRoot   = "/proc"
+	cgroupRoot = "/sys/fs/cgroup"
+)
+
+// cgroupV1Unlimited is the smallest memory.limit_in_bytes treated as no limit; unlimited v1 cgroups
+// report a huge page aligned value rather than a marker.
+const cgroupV1Unlimited = 1 << 62
+
+// detectMemoryLimits reads the memory controller of the cgroup of the process and /proc/meminfo.
+func detectMemoryLimits() (MemoryLimits, error) {
+	var l MemoryLimits
+	meminfo, err := os.ReadFile(filepath.Join(procRoot, "meminfo"))
+	if err == nil {
+		if kb, found := statValue(meminfo, "MemAvailable:"); found {
+			l.Available = kb * 1024
+		}
+	}
+	cgroups, err := os.ReadFile(filepath.Join(procRoot, "self", "cgroup"))
+	if err != nil {
+		return l, nil
+	}
+	sc := bufio.NewScanner(bytes.NewReader(cgroups))
+	for sc.Scan() {
+		// hierarchy-ID:controllers:path, with an empty controller list for cgroup v2
+		fields := strings.SplitN(sc.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		switch {
+		case fields[0] == "0" && fields[1] == "":
+			l.cgroupV2(fields[2])
+		case hasController(fields[1], "memory"):
+			l.cgroupV1(fields[2])
+		}
+		if l.CgroupVersion != 0 {
+			break
+		}
+	}
+	return l, sc.Err()
+}
+
+// cgroupV2 finds the smallest memory.max from the cgroup of the process up to the root.
+func (l *MemoryLimits) cgroupV2(path string) {
+	dir := filepath.Join(cgroupRoot, path)
+	if _, err := os.Stat(dir); err != nil {
+		// in a cgroup namespace the path is relative to a mount we may not see
+		dir = cgroupRoot
+	}
+	for {
+		limit, err := readCgroupValue(filepath.Join(dir, "memory.max"))
+		if err == nil && limit > 0 && (l.CgroupLimit == 0 || limit < l.CgroupLimit) {
+			usage, _ := readCgroupValue(filepath.Join(dir, "memory.current"))
+			stat, _ := os.ReadFile(filepath.Join(dir, "memory.stat"))
+			inactive, _ := statValue(stat, "inactive_file")
+			l.CgroupVersion, l.CgroupLimit, l.CgroupUsage = 2, limit, usage-min(usage, inactive)
+		}
+		if dir == cgroupRoot || len(dir) <= len(cgroupRoot) {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// cgroupV1 reads the memory controller of the cgroup of the process.
+func (l *MemoryLimits) cgroupV1(path string) {
+	dir := filepath.Join(cgroupRoot, "memory", path)
+	if _, err := os.Stat(dir); err != nil {
+		dir = filepath.Join(cgroupRoot, "memory")
+	}
+	limit, err := readCgroupValue(filepath.Join(dir, "memory.limit_in_bytes"))
+	if err != nil || limit == 0 || limit >= cgroupV1Unlimited {
+		return
+	}
+	usage, _ := readCgroupValue(filepath.Join(dir, "memory.usage_in_bytes"))
+	stat, _ := os.ReadFile(filepath.Join(dir, "memory.stat"))
+	inactive, _ := statValue(stat, "total_inactive_file")
+	l.CgroupVersion, l.CgroupLimit, l.CgroupUsage = 1, limit, usage-min(usage, inactive)
+}
+
+// readCgroupValue reads a file holding a number of bytes, or "max" which gives 0.
+func readCgroupValue(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(data))
+	if s == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// statValue returns the number following key in a file of "key value" lines such as memory.stat or meminfo.
+func statValue(data []byte, key string) (uint64, bool) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == key {
+			v, err := strconv.ParseUint(fields[1], 10, 64)
+			return v, err == nil
+		}
+	}
+	return 0, false
+}
+
+func hasController(list string, name string) bool {
+	for _, c := range strings.Split(list, ",") {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package argon2_go_withsecret
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDetectMemoryLimitsLinux(t *testing.T) {
+	defer func(p, c string) { procRoot, cgroupRoot = p, c }(procRoot, cgroupRoot)
+	meminfo := "MemTotal:       16000000 kB\nMemAvailable:    8000000 kB\n"
+
+	vectors := []struct {
+		name  string
+		files map[string]string
+		want  MemoryLimits
+	}{
+		{"v2 pod", map[string]string{
+			"proc/meminfo":                         meminfo,
+			"proc/self/cgroup":                     "0::/kubepods/pod1/app\n",
+			"cgroup/memory.max":                    "max\n",
+			"cgroup/kubepods/pod1/memory.max":      "536870912\n",
+			"cgroup/kubepods/pod1/memory.current":  "209715200\n",
+			"cgroup/kubepods/pod1/memory.stat":     "anon 104857600\ninactive_file 104857600\n",
+			"cgroup/kubepods/pod1/app/memory.max":  "max\n",
+			"cgroup/kubepods/pod1/app/memory.stat": "anon 1\n",
+		}, MemoryLimits{2, 512 << 20, 100 << 20, 8000000 * 1024}},
+		{"v2 namespaced", map[string]string{
+			"proc/self/cgroup":      "0::/\n",
+			"cgroup/memory.max":     "268435456\n",
+			"cgroup/memory.current": "1048576\n",
+		}, MemoryLimits{2, 256 << 20, 1 << 20, 0}},
+		{"v1", map[string]string{
+			"proc/meminfo":                        meminfo,
+			"proc/self/cgroup":                    "5:cpu,cpuacct:/docker/abc\n4:memory:/docker/abc\n",
+			"cgroup/memory/memory.limit_in_bytes": "1073741824\n",
+			"cgroup/memory/memory.usage_in_bytes": "2097152\n",
+			"cgroup/memory/memory.stat":           "total_inactive_file 1048576\n",
+		}, MemoryLimits{1, 1 << 30, 1 << 20, 8000000 * 1024}},
+		{"v1 unlimited", map[string]string{
+			"proc/meminfo":                        meminfo,
+			"proc/self/cgroup":                    "4:memory:/\n",
+			"cgroup/memory/memory.limit_in_bytes": "9223372036854771712\n",
+		}, MemoryLimits{Available: 8000000 * 1024}},
+		{"none", map[string]string{}, MemoryLimits{}},
+	}
+	for _, v := range vectors {
+		root := t.TempDir()
+		writeFiles(t, root, v.files)
+		procRoot, cgroupRoot = filepath.Join(root, "proc"), filepath.Join(root, "cgroup")
+		got, err := DetectMemoryLimits()
+		if err != nil || got != v.want {
+			t.Errorf("%s: got %+v, %v  want %+v", v.name, got, err, v.want)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package argon2_go_withsecret
+
+// detectMemoryLimits knows no limits outside Linux.
+func detectMemoryLimits() (MemoryLimits, error) {
+	return MemoryLimits{}, nil
+}
@@ -0,0 +1,113 @@
+package argon2_go_withsecret
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimitsHeadroom(t *testing.T) {
+	vectors := []struct {
+		limits   MemoryLimits
+		headroom uint64
+		known    bool
+	}{
+		{MemoryLimits{}, 0, false},
+		{MemoryLimits{Available: 8 << 30}, 8 << 30, true},
+		{MemoryLimits{CgroupVersion: 2, CgroupLimit: 512 << 20, CgroupUsage: 100 << 20, Available: 8 << 30}, 412 << 20, true},
+		{MemoryLimits{CgroupVersion: 2, CgroupLimit: 512 << 20, CgroupUsage: 100 << 20, Available: 64 << 20}, 64 << 20, true},
+		{MemoryLimits{CgroupVersion: 1, CgroupLimit: 512 << 20, CgroupUsage: 600 << 20}, 0, true},
+	}
+	for i, v := range vectors {
+		headroom, known := v.limits.Headroom()
+		if headroom != v.headroom || known != v.known {
+			t.Errorf("%d: got %d, %v  want %d, %v", i, headroom, known, v.headroom, v.known)
+		}
+	}
+	max, _ := MemoryLimits{CgroupLimit: 400 << 20}.MaxMemory(0.25)
+	if max != 300<<10 {
+		t.Errorf("MaxMemory: got %d KiB  want %d", max, 300<<10)
+	}
+}
+
+func TestMemoryPreflight(t *testing.T) {
+	defer func(f func() (MemoryLimits, error)) { memoryLimits = f }(memoryLimits)
+	memoryLimits = func() (MemoryLimits, error) {
+		return MemoryLimits{CgroupVersion: 2, CgroupLimit: 128 << 20, CgroupUsage: 16 << 20}, nil
+	}
+	SetMemoryPreflight(0.25)
+	defer SetMemoryPreflight(0)
+
+	// 84 MiB is allowed, the 256 MiB of NewVaultContext is not
+	obs := &recordingObserver{}
+	_, err := NewVaultContext().SetObserver(obs).Hash([]byte("password"), []byte("somesalt"))
+	if err != ErrMemoryPreflight {
+		t.Errorf("vault: got %v  want %v", err, ErrMemoryPreflight)
+	}
+	if len(obs.seen) != 1 || obs.seen[0].Outcome != OutcomeError {
+		t.Errorf("observations: %+v", obs.seen)
+	}
+	_, err = NewContext().SetMemory(1<<10).Hash([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Errorf("1 MiB: got %v", err)
+	}
+
+	SetMemoryPreflight(0)
+	memoryLimits = func() (MemoryLimits, error) { return MemoryLimits{}, nil }
+	_, err = NewContext().SetMemory(1<<10).Hash([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Errorf("disabled: got %v", err)
+	}
+}
+
+// unlockedWriter records whether the package mutex was free whenever a log line is written.
+type unlockedWriter struct {
+	lines, locked int
+}
+
+func (w *unlockedWriter) Write(p []byte) (int, error) {
+	w.lines++
+	if mutex.TryLock() {
+		mutex.Unlock()
+	} else {
+		w.locked++
+	}
+	return len(p), nil
+}
+
+func TestMemoryPreflightCached(t *testing.T) {
+	defer func(f func() (MemoryLimits, error)) { memoryLimits = f }(memoryLimits)
+	defer func(ttl time.Duration) { memoryLimitsTTL = ttl }(memoryLimitsTTL)
+	memoryLimitsTTL = time.Hour
+	reads := 0
+	memoryLimits = func() (MemoryLimits, error) {
+		reads++
+		return MemoryLimits{CgroupVersion: 2, CgroupLimit: 128 << 20, CgroupUsage: 16 << 20}, nil
+	}
+	SetMemoryPreflight(0.25)
+	defer SetMemoryPreflight(0)
+
+	w := &unlockedWriter{}
+	ctx := NewContext().SetMemory(1 << 10).SetLogger(slog.New(slog.NewTextHandler(w, nil)))
+	for i := 0; i < 3; i++ {
+		if _, err := ctx.Hash([]byte("password"), []byte("somesalt")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ctx.Clone().SetMemory(1<<17).Hash([]byte("password"), []byte("somesalt")); err != ErrMemoryPreflight {
+		t.Errorf("128 MiB: got %v  want %v", err, ErrMemoryPreflight)
+	}
+	if reads != 1 {
+		t.Errorf("limits read %d times  want 1", reads)
+	}
+	if w.lines == 0 || w.locked != 0 {
+		t.Errorf("%d of %d log lines written holding the package mutex", w.locked, w.lines)
+	}
+
+	// SetMemoryPreflight reads them again
+	SetMemoryPreflight(0.25)
+	ctx.Hash([]byte("password"), []byte("somesalt"))
+	if reads != 2 {
+		t.Errorf("limits read %d times after SetMemoryPreflight  want 2", reads)
+	}
+}
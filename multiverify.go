@@ -0,0 +1,224 @@
+package argon2_go_withsecret
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	ErrUnknownHashFormat = errors.New("argon2-go-withsecret: unknown modular crypt format")
+	ErrLegacyFormat      = errors.New("argon2-go-withsecret: cannot parse legacy hash")
+)
+
+// LegacyVerifier verifies a password against a hash in a non Argon2 modular crypt format.
+// A wrong password is reported as false with a nil error.
+type LegacyVerifier interface {
+	Verify(encoded string, password []byte) (bool, error)
+}
+
+// LegacyVerifierFunc adapts an ordinary function to the LegacyVerifier interface.
+type LegacyVerifierFunc func(encoded string, password []byte) (bool, error)
+
+func (f LegacyVerifierFunc) Verify(encoded string, password []byte) (bool, error) {
+	return f(encoded, password)
+}
+
+// bcryptWorkPerRound and pbkdf2RoundsPerWork compare the cost of legacy hashes with Argon2 work, in passes over
+// 1 KiB of memory, so that SetVerifyLimits bounds them too. They are rough single core timings.
+const (
+	bcryptWorkPerRound  = 32 // one of the 2^cost key expansion rounds of bcrypt
+	pbkdf2RoundsPerWork = 4
+)
+
+// MultiVerifier verifies Argon2 encodings with a Context and legacy bcrypt, scrypt and PBKDF2 hashes
+// with registered LegacyVerifiers, dispatching on the $id$ prefix.
+// When a legacy hash verifies it returns a replacement Argon2 encoding made with a copy of the Context.
+// Legacy verifiers run under the package mutex like Argon2 verifications.
+type MultiVerifier struct {
+	ctx    *Context
+	legacy map[string]LegacyVerifier // by modular crypt id without the $ signs
+}
+
+// NewMultiVerifier creates a MultiVerifier that uses ctx, including its secret, for Argon2 verification
+// and replacement hashes. ctx itself is never mutated. bcrypt ($2a$, $2b$, $2y$), scrypt ($scrypt$) and
+// PBKDF2 ($pbkdf2$, $pbkdf2-sha256$, $pbkdf2-sha512$) in passlib format are registered. They refuse with
+// ErrVerifyLimit, before hashing, legacy hashes whose memory or work exceed the SetVerifyLimits of ctx.
+func NewMultiVerifier(ctx *Context) *MultiVerifier {
+	mv := &MultiVerifier{ctx: ctx, legacy: map[string]LegacyVerifier{}}
+	for _, id := range []string{"2", "2a", "2b", "2x", "2y"} {
+		mv.Register(id, LegacyVerifierFunc(mv.verifyBcrypt))
+	}
+	mv.Register("scrypt", LegacyVerifierFunc(mv.verifyScrypt))
+	mv.Register("pbkdf2", mv.pbkdf2Verifier(sha1.New))
+	mv.Register("pbkdf2-sha256", mv.pbkdf2Verifier(sha256.New))
+	mv.Register("pbkdf2-sha512", mv.pbkdf2Verifier(sha512.New))
+	return mv
+}
+
+// Register adds or replaces the LegacyVerifier for encodings starting with $id$.
+func (mv *MultiVerifier) Register(id string, v LegacyVerifier) *MultiVerifier {
+	mv.legacy[id] = v
+	return mv
+}
+
+// Verify checks password against encoded, an Argon2 encoding in any form accepted by VerifyEncoded or a legacy hash.
+// If encoded is a legacy hash, or a legacy hash wrapped by WrapLegacy, and the password is correct,
+// replacement holds a new Argon2 encoding with a random salt that should be stored in its place,
+// even if the password fails the password policy of the Context.
+// Otherwise replacement is empty.
+func (mv *MultiVerifier) Verify(encoded string, password []byte) (ok bool, replacement string, err error) {
+	// Argon2 encodings written by other libraries are recognised in their native form
+	_, native := DecodeFormat(encoded)
+	id := modularCryptID(native)
+	atype, _, _ := strings.Cut(id, "-wrap-")
+	if _, err := argon2_string2type(atype); err == nil {
+		ctx := mv.ctx.Clone()
+		ok, err = ctx.VerifyEncoded(encoded, password)
+		if err != nil || !ok || !ctx.NeedsRehash() {
+			return ok, "", err
+		}
+		return mv.replace(id, password)
+	}
+	v, found := mv.legacy[id]
+	if !found {
+		return false, "", ErrUnknownHashFormat
+	}
+	ok, err = verifyLegacy(v, encoded, password)
+	if err != nil || !ok {
+		return false, "", err
+	}
+	return mv.replace(id, password)
+}
+
+// replace hashes a password that verified against a legacy hash of type id.
+// The password policy is not applied, as it would keep a weak password on the legacy hash.
+func (mv *MultiVerifier) replace(id string, password []byte) (bool, string, error) {
+	ctx := mv.ctx.Clone().SetPasswordPolicy(nil)
+	ctx.logDecision(slog.LevelInfo, "replacing legacy hash", slog.String("algorithm", id))
+	replacement, err := ctx.HashEncodedRandomSalt(password)
+	if err != nil {
+		return true, "", err
+	}
+	return true, replacement, nil
+}
+
+// verifyLegacy runs v under the package mutex, so legacy hashes queue with Argon2 hashes instead of adding to them.
+func verifyLegacy(v LegacyVerifier, encoded string, password []byte) (bool, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return v.Verify(encoded, password)
+}
+
+// checkLegacyCost compares the memory in KiB and the work, in Argon2 passes over 1 KiB, of a legacy hash
+// with the verify limits of the Context. Work is limited when both memory and iterations are.
+func (ctx *Context) checkLegacyCost(memory float64, work float64) error {
+	limit := ctx.verifyLimit
+	if (limit.Memory > 0 && memory > float64(limit.Memory)) ||
+		(limit.Memory > 0 && limit.Iterations > 0 && work > float64(limit.Memory)*float64(limit.Iterations)) {
+		ctx.logDecision(slog.LevelWarn, "refusing legacy hash beyond verify limits", slog.Any("limits", limit))
+		return ErrVerifyLimit
+	}
+	return nil
+}
+
+// checkBcryptCost is checkLegacyCost for a bcrypt cost, with its 4 KiB of Blowfish state.
+func (ctx *Context) checkBcryptCost(cost int) error {
+	return ctx.checkLegacyCost(4, float64(uint64(1)<<uint(cost))*bcryptWorkPerRound)
+}
+
+// modularCryptID returns the id of a $id$... encoding.
+func modularCryptID(encoded string) string {
+	if !strings.HasPrefix(encoded, "$") {
+		return ""
+	}
+	id, _, _ := strings.Cut(encoded[1:], "$")
+	return id
+}
+
+func (mv *MultiVerifier) verifyBcrypt(encoded string, password []byte) (bool, error) {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return false, err
+	}
+	err = mv.ctx.checkBcryptCost(cost)
+	if err != nil {
+		return false, err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(encoded), password)
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// verifyScrypt verifies $scrypt$ln=<log2 N>,r=<r>,p=<p>$<salt>$<hash> as written by passlib.
+func (mv *MultiVerifier) verifyScrypt(encoded string, password []byte) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 {
+		return false, ErrLegacyFormat
+	}
+	var ln, r, p int
+	n, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &ln, &r, &p)
+	if n != 3 || err != nil || ln < 1 || ln > 30 || r < 1 || p < 1 || r*p >= 1<<30 {
+		return false, ErrLegacyFormat
+	}
+	// 128 r N bytes, written and read once per p
+	memory := float64(uint64(1)<<uint(ln)) * float64(r) / 8
+	err = mv.ctx.checkLegacyCost(memory, 2*memory*float64(p))
+	if err != nil {
+		return false, err
+	}
+	salt, err1 := decodeAdaptedBase64(parts[3])
+	want, err2 := decodeAdaptedBase64(parts[4])
+	if err1 != nil || err2 != nil || len(want) == 0 {
+		return false, ErrLegacyFormat
+	}
+	got, err := scrypt.Key(password, salt, 1<<uint(ln), r, p, len(want))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+// pbkdf2Verifier verifies $pbkdf2[-digest]$<rounds>$<salt>$<hash> as written by passlib.
+func (mv *MultiVerifier) pbkdf2Verifier(h func() hash.Hash) LegacyVerifierFunc {
+	return func(encoded string, password []byte) (bool, error) {
+		parts := strings.Split(encoded, "$")
+		if len(parts) != 5 {
+			return false, ErrLegacyFormat
+		}
+		var rounds int
+		n, err := fmt.Sscanf(parts[2], "%d", &rounds)
+		if n != 1 || err != nil || rounds < 1 {
+			return false, ErrLegacyFormat
+		}
+		err = mv.ctx.checkLegacyCost(0, float64(rounds)/pbkdf2RoundsPerWork)
+		if err != nil {
+			return false, err
+		}
+		salt, err1 := decodeAdaptedBase64(parts[3])
+		want, err2 := decodeAdaptedBase64(parts[4])
+		if err1 != nil || err2 != nil || len(want) == 0 {
+			return false, ErrLegacyFormat
+		}
+		got := pbkdf2.Key(password, salt, rounds, len(want), h)
+		return subtle.ConstantTimeCompare(got, want) == 1, nil
+	}
+}
+
+// decodeAdaptedBase64 decodes passlib's unpadded base64, which may use '.' in place of '+'.
+func decodeAdaptedBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(s, ".", "+"))
+}
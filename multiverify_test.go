@@ -0,0 +1,152 @@
+package argon2_go_withsecret
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+func TestMultiVerifier(t *testing.T) {
+	password := []byte("password")
+	salt := []byte("saltsaltsaltsalt")
+
+	bc, err := bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := scrypt.Key(password, salt, 1<<10, 8, 1, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := fmt.Sprintf("$scrypt$ln=10,r=8,p=1$%s$%s",
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(sk))
+	pk := pbkdf2.Key(password, salt, 1000, 32, sha256.New)
+	pb := fmt.Sprintf("$pbkdf2-sha256$1000$%s$%s",
+		strings.ReplaceAll(base64.RawStdEncoding.EncodeToString(salt), "+", "."),
+		strings.ReplaceAll(base64.RawStdEncoding.EncodeToString(pk), "+", "."))
+
+	ctx := NewContext().SetMemory(1 << 10).SetSecret([]byte("somesecret"))
+	mv := NewMultiVerifier(ctx)
+
+	for _, legacy := range []string{string(bc), sc, pb} {
+		ok, replacement, err := mv.Verify(legacy, []byte("wrongpassword"))
+		if ok || replacement != "" || err != nil {
+			t.Errorf("%s wrong password: got %v, %q, %v", legacy, ok, replacement, err)
+		}
+
+		ok, replacement, err = mv.Verify(legacy, password)
+		if !ok || err != nil {
+			t.Fatalf("%s: got %v, %v  want true", legacy, ok, err)
+		}
+		if !strings.HasPrefix(replacement, "$argon2id$v=19$m=1024,t=3,p=2$") {
+			t.Errorf("%s: unexpected replacement %q", legacy, replacement)
+		}
+
+		// the replacement is an argon2 hash using the secret
+		ok, again, err := mv.Verify(replacement, password)
+		if !ok || again != "" || err != nil {
+			t.Errorf("replacement: got %v, %q, %v", ok, again, err)
+		}
+		ok, _ = NewContext().VerifyEncoded(replacement, password)
+		if ok {
+			t.Errorf("replacement verified without the secret")
+		}
+	}
+	if ctx.GetMemory() != 1<<10 {
+		t.Errorf("MultiVerifier mutated its Context")
+	}
+
+	_, _, err = mv.Verify("$1$saltsalt$hash", password)
+	if err != ErrUnknownHashFormat {
+		t.Errorf("got %v want %v", err, ErrUnknownHashFormat)
+	}
+	_, _, err = mv.Verify("$scrypt$ln=10$salt$hash", password)
+	if err != ErrLegacyFormat {
+		t.Errorf("got %v want %v", err, ErrLegacyFormat)
+	}
+}
+
+func TestMultiVerifierFormats(t *testing.T) {
+	ctx := NewContext().SetMemory(1 << 10)
+	mv := NewMultiVerifier(ctx)
+	for _, mode := range []int{ModeArgon2i, ModeArgon2id} {
+		native, err := ctx.Clone().SetMode(mode).HashEncoded([]byte("password"), []byte("somesalt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range []Format{FormatNative, FormatSodium, FormatDjango, FormatLDAP, FormatDovecot} {
+			s, err := EncodeFormat(f, native)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f == FormatSodium {
+				s += strings.Repeat("\x00", 128-len(s))
+			}
+			ok, replacement, err := mv.Verify(s, []byte("password"))
+			if !ok || replacement != "" || err != nil {
+				t.Errorf("%v %q: got %v, %q, %v  want true", f, s, ok, replacement, err)
+			}
+			ok, _, err = mv.Verify(s, []byte("wrongpassword"))
+			if ok || err != nil {
+				t.Errorf("%v %q wrong password: got %v, %v  want false", f, s, ok, err)
+			}
+		}
+	}
+}
+
+func TestMultiVerifierLimits(t *testing.T) {
+	bc, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := base64.RawStdEncoding.EncodeToString([]byte("saltsaltsaltsalt"))
+	ctx := NewContext().SetMemory(1<<10).SetVerifyLimits(1<<16, 3, 4)
+	mv := NewMultiVerifier(ctx)
+
+	for _, legacy := range []string{
+		strings.Replace(string(bc), "$04$", "$31$", 1),
+		"$scrypt$ln=20,r=8,p=1$" + salt + "$" + salt,
+		"$scrypt$ln=4,r=1073741823,p=1$" + salt + "$" + salt,
+		"$scrypt$ln=16,r=8,p=64$" + salt + "$" + salt,
+		"$pbkdf2-sha256$10000000$" + salt + "$" + salt,
+	} {
+		ok, _, err := mv.Verify(legacy, []byte("password"))
+		if ok || err != ErrVerifyLimit {
+			t.Errorf("%s: got %v, %v  want %v", legacy, ok, err, ErrVerifyLimit)
+		}
+	}
+	ok, _, err := mv.Verify(string(bc), []byte("password"))
+	if !ok || err != nil {
+		t.Errorf("bcrypt within limits: got %v, %v", ok, err)
+	}
+}
+
+func TestMultiVerifierMutex(t *testing.T) {
+	bc, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mv := NewMultiVerifier(NewContext().SetMemory(1 << 10))
+	done := make(chan bool)
+	mutex.Lock()
+	go func() {
+		ok, _, _ := mv.Verify(string(bc), []byte("password"))
+		done <- ok
+	}()
+	select {
+	case <-done:
+		t.Fatal("legacy verification ran while the package mutex was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	mutex.Unlock()
+	if !<-done {
+		t.Error("bcrypt did not verify")
+	}
+}
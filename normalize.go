@@ -0,0 +1,110 @@
+package argon2_go_withsecret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/secure/precis"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is a Unicode normalization applied to passwords before hashing, see SetNormalization.
+type Normalization string
+
+const (
+	NormalizeNone   Normalization = ""
+	NormalizeNFC    Normalization = "nfc"    // canonical composition, "é" typed on any system gives the same bytes
+	NormalizeNFKC   Normalization = "nfkc"   // also folds compatibility forms such as full width letters and ligatures
+	NormalizePRECIS Normalization = "precis" // OpaqueString profile of RFC 8265, the standard for passwords
+)
+
+// normTrim is appended to the norm= parameter when surrounding white space is trimmed.
+const normTrim = "trim"
+
+var ErrNormalization = errors.New("argon2-go-withsecret: cannot normalize password")
+
+// sets Context fields. Passwords are normalized before HashEncoded hashes them, after which white space at either
+// end is removed if trim is set. The encoding records this as norm=, for example norm=nfc-trim, so that
+// VerifyEncoded applies the same transform. Hash and Verify, which have no encoding, are not affected.
+// Normalizing changes the hash of non ASCII passwords, so existing hashes keep verifying as they were made.
+// It panics if form is not one of the Normalization constants.
+func (ctx *Context) SetNormalization(form Normalization, trim bool) *Context {
+	if !form.valid() {
+		panic(fmt.Sprintf("%v: unknown form %q", ErrNormalization, string(form)))
+	}
+	ctx.normalization = normalizationOption(form, trim)
+	return ctx
+}
+
+// valid reports whether form is one of the Normalization constants.
+func (form Normalization) valid() bool {
+	switch form {
+	case NormalizeNone, NormalizeNFC, NormalizeNFKC, NormalizePRECIS:
+		return true
+	}
+	return false
+}
+
+// normalizationOption returns the canonical norm= value of form and trim, "" for none.
+func normalizationOption(form Normalization, trim bool) string {
+	if !trim {
+		return string(form)
+	}
+	return strings.TrimPrefix(string(form)+"-"+normTrim, "-")
+}
+
+// setNormalizationOption sets Context fields from the norm= parameter of an encoding, in canonical form
+// so that a re-encoding writes what encode would, norm=trim for norm=-trim.
+func (ctx *Context) setNormalizationOption(value string) error {
+	form, trim, dash := strings.Cut(value, "-")
+	if form == normTrim && !dash {
+		form, trim, dash = "", normTrim, true
+	}
+	if value == "" || !Normalization(form).valid() || (dash && trim != normTrim) {
+		return ErrEncodedFormatBadParameter
+	}
+	ctx.normalization = normalizationOption(Normalization(form), dash)
+	return nil
+}
+
+// normalizePassword applies the Context normalization to password, see SetNormalization.
+// With FlagClearPassword the original is cleared, as libargon2 only clears the normalized copy.
+func (ctx *Context) normalizePassword(password []byte) ([]byte, error) {
+	if ctx.normalization == "" {
+		return password, nil
+	}
+	form, trim, _ := strings.Cut(ctx.normalization, "-")
+	if form == normTrim {
+		form, trim = "", normTrim
+	}
+	var out []byte
+	switch Normalization(form) {
+	case NormalizeNone:
+		out = password
+	case NormalizeNFC:
+		out = norm.NFC.Bytes(password)
+	case NormalizeNFKC:
+		out = norm.NFKC.Bytes(password)
+	case NormalizePRECIS:
+		var err error
+		out, err = precis.OpaqueString.Bytes(password)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNormalization, err)
+		}
+	default:
+		return nil, ErrNormalization
+	}
+	if trim == normTrim {
+		out = bytes.TrimSpace(out)
+	}
+	if ctx.Flags&FlagClearPassword != 0 {
+		// out may be password itself or a part of it, when already normalized or only trimmed
+		out = append([]byte(nil), out...)
+		for i := range password {
+			password[i] = 0
+		}
+	}
+	return out, nil
+}
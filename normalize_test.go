@@ -0,0 +1,133 @@
+package argon2_go_withsecret
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalization(t *testing.T) {
+	composed := "caf\u00e9 ol\u00e9"     // as typed on Windows and Linux
+	decomposed := "cafe\u0301 ole\u0301" // as typed on macOS
+	vectors := []struct {
+		form     Normalization
+		trim     bool
+		norm     string
+		password string
+		matches  []string
+		differs  []string
+	}{
+		{NormalizeNFC, false, "nfc", composed, []string{decomposed}, []string{" " + composed}},
+		{NormalizeNFC, true, "nfc-trim", composed, []string{decomposed + " \t", " " + composed}, nil},
+		{NormalizeNFKC, false, "nfkc", "ｐａｓｓ ﬁle", []string{"pass file"}, []string{"pass  file"}},
+		{NormalizePRECIS, false, "precis", composed, []string{decomposed, "caf\u00e9\u00a0ol\u00e9"}, nil},
+		{NormalizeNone, true, "trim", "password", []string{" password\n"}, []string{"pass word"}},
+	}
+	for _, v := range vectors {
+		ctx := NewContext().SetMemory(1<<10).SetNormalization(v.form, v.trim)
+		s, err := ctx.HashEncoded([]byte(v.password), []byte("somesalt"))
+		if err != nil {
+			t.Fatalf("%s: %v", v.norm, err)
+		}
+		if !strings.Contains(s, ",norm="+v.norm+"$") {
+			t.Errorf("%s: not recorded in %s", v.norm, s)
+		}
+		for _, pw := range append(v.matches, v.password) {
+			if ok, err := NewContext().VerifyEncoded(s, []byte(pw)); !ok || err != nil {
+				t.Errorf("%s %q: got %v, %v  want true", v.norm, pw, ok, err)
+			}
+		}
+		for _, pw := range v.differs {
+			if ok, _ := NewContext().VerifyEncoded(s, []byte(pw)); ok {
+				t.Errorf("%s %q: matched", v.norm, pw)
+			}
+		}
+		ph, err := ParsePasswordHash(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back PasswordHash
+		if b, err := ph.MarshalBinary(); err != nil || back.UnmarshalBinary(b) != nil || back.String() != s {
+			t.Errorf("%s: binary round trip: %v, %s", v.norm, err, back.String())
+		}
+		if _, err := EncodeFormat(FormatDjango, s); err != ErrFormatParams {
+			t.Errorf("%s: django: got %v  want %v", v.norm, err, ErrFormatParams)
+		}
+	}
+
+	// without normalization the two forms are different passwords
+	s, _ := NewContext().SetMemory(1<<10).HashEncoded([]byte(composed), []byte("somesalt"))
+	if ok, _ := NewContext().VerifyEncoded(s, []byte(decomposed)); ok {
+		t.Error("unnormalized hash matched the other form")
+	}
+
+	// with FlagClearPassword an already normalized or only trimmed password must be hashed before it is cleared
+	for _, v := range vectors {
+		for _, account := range []string{"", "alice"} {
+			ctx := NewContext().SetMemory(1<<10).SetNormalization(v.form, v.trim).SetFlags(FlagClearPassword)
+			pw := []byte(" password")
+			var s string
+			var err error
+			if account == "" {
+				s, err = ctx.HashEncoded(pw, []byte("somesalt"))
+			} else {
+				s, err = ctx.HashEncodedFor(account, pw)
+			}
+			if err != nil {
+				t.Fatalf("%s clear %q: %v", v.norm, account, err)
+			}
+			if strings.Trim(string(pw), "\x00") != "" {
+				t.Errorf("%s clear %q: password not cleared: %q", v.norm, account, pw)
+			}
+			verify := func(pw string) (bool, error) {
+				if account == "" {
+					return NewContext().VerifyEncoded(s, []byte(pw))
+				}
+				return NewContext().VerifyEncodedFor(account, s, []byte(pw))
+			}
+			if ok, err := verify(" password"); !ok || err != nil {
+				t.Errorf("%s clear %q: got %v, %v  want true", v.norm, account, ok, err)
+			}
+			if ok, _ := verify(" PASSWORD"); ok {
+				t.Errorf("%s clear %q: a different password matched", v.norm, account)
+			}
+		}
+	}
+
+	_, err := NewContext().SetNormalization(NormalizePRECIS, false).HashEncoded([]byte("bell\x07"), []byte("somesalt"))
+	if !errors.Is(err, ErrNormalization) {
+		t.Errorf("control character: got %v  want %v", err, ErrNormalization)
+	}
+	_, err = NewContext().VerifyEncoded(strings.Replace(s, "p=2$", "p=2,norm=nfd$", 1), []byte(composed))
+	if err != ErrEncodedFormatBadParameter {
+		t.Errorf("norm=nfd: got %v  want %v", err, ErrEncodedFormatBadParameter)
+	}
+	_, err = NewContext().VerifyEncoded(strings.Replace(s, "p=2$", "p=2,norm=nfc-$", 1), []byte(composed))
+	if err != ErrEncodedFormatBadParameter {
+		t.Errorf("norm=nfc-: got %v  want %v", err, ErrEncodedFormatBadParameter)
+	}
+}
+
+func TestNormalizationCanonical(t *testing.T) {
+	ctx := NewContext().SetMemory(1 << 10).SetNormalization(NormalizeNone, true)
+	s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = strings.Replace(s, ",norm=trim$", ",norm=-trim$", 1)
+	ctx = NewContext()
+	if ok, err := ctx.VerifyEncoded(s, []byte(" password")); !ok || err != nil {
+		t.Fatalf("norm=-trim: got %v, %v  want true", ok, err)
+	}
+	again, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil || !strings.Contains(again, ",norm=trim$") {
+		t.Errorf("norm=-trim: re-encoded as %s, %v", again, err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("SetNormalization(\"nfd\"): no panic")
+		}
+	}()
+	NewContext().SetNormalization("nfd", false)
+}
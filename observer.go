@@ -0,0 +1,132 @@
+package argon2_go_withsecret
+
+import (
+	"sync"
+	"time"
+)
+
+// Operation names reported in Observation.Op
+const (
+	OpHash          = "hash"
+	OpVerify        = "verify"
+	OpVerifyEncoded = "verifyencoded"
+)
+
+// Outcome of an observed operation.
+type Outcome int
+
+const (
+	OutcomeOK       Outcome = iota // Hash succeeded
+	OutcomeMatch                   // Verify found the password correct
+	OutcomeMismatch                // Verify found the password wrong
+	OutcomeError                   // the operation returned an error
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeOK:
+		return "ok"
+	case OutcomeMatch:
+		return "match"
+	case OutcomeMismatch:
+		return "mismatch"
+	default:
+		return "error"
+	}
+}
+
+// Observation describes one Hash, Verify or VerifyEncoded call.
+// QueueWait is the time spent waiting for the package mutex and Compute the time spent inside libargon2.
+// Params.Memory gives the memory used in KiB.
+type Observation struct {
+	Op        string
+	Params    Params
+	QueueWait time.Duration
+	Compute   time.Duration
+	Outcome   Outcome
+	Err       error
+}
+
+// Observer is invoked after every Hash, Verify and VerifyEncoded call.
+// It is called outside the package mutex but must be safe for concurrent use and should return quickly.
+type Observer interface {
+	Observe(o *Observation)
+}
+
+// ObserverFunc adapts an ordinary function to the Observer interface.
+type ObserverFunc func(o *Observation)
+
+func (f ObserverFunc) Observe(o *Observation) {
+	f(o)
+}
+
+var (
+	observerMutex   sync.RWMutex
+	defaultObserver Observer
+)
+
+// SetDefaultObserver sets the Observer used by every Context that has no Observer of its own.
+// nil disables observation.
+func SetDefaultObserver(o Observer) {
+	observerMutex.Lock()
+	defer observerMutex.Unlock()
+	defaultObserver = o
+}
+
+// sets Context fields. Overrides the default observer for this Context only.
+func (ctx *Context) SetObserver(o Observer) *Context {
+	ctx.observer = o
+	return ctx
+}
+
+func (ctx *Context) observe(o *Observation) {
+	obs := ctx.observer
+	if obs == nil {
+		observerMutex.RLock()
+		obs = defaultObserver
+		observerMutex.RUnlock()
+	}
+	if obs != nil {
+		obs.Observe(o)
+	}
+}
+
+// run calls fn while holding the package mutex, after the memory preflight, and reports the timings to the observer
+// and to the hash speed average used by HashEncodedWithin.
+// For op OpHash the bool result of fn means success, otherwise it means the password matched.
+func (ctx *Context) run(op string, fn func() (bool, error)) (ok bool, err error) {
+	start := time.Now()
+	acquired := start
+	err = ctx.preflight()
+	if err == nil {
+		func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			acquired = time.Now()
+			ok, err = fn()
+		}()
+	}
+	done := time.Now()
+
+	o := &Observation{
+		Op:        op,
+		Params:    ctx.GetParams(),
+		QueueWait: acquired.Sub(start),
+		Compute:   done.Sub(acquired),
+		Err:       err,
+	}
+	switch {
+	case err != nil:
+		o.Outcome = OutcomeError
+	case op == OpHash:
+		o.Outcome = OutcomeOK
+	case ok:
+		o.Outcome = OutcomeMatch
+	default:
+		o.Outcome = OutcomeMismatch
+	}
+	recordThroughput(ctx.throughputKey(), o)
+	ctx.observe(o)
+	ctx.logIfSlow(o)
+	return ok, err
+}
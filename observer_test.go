@@ -0,0 +1,71 @@
+package argon2_go_withsecret
+
+import (
+	"sync"
+	"testing"
+)
+
+type recordingObserver struct {
+	mu   sync.Mutex
+	seen []Observation
+}
+
+func (r *recordingObserver) Observe(o *Observation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seen = append(r.seen, *o)
+}
+
+func TestObserver(t *testing.T) {
+	rec := &recordingObserver{}
+	ctx := NewContext().SetMemory(1 << 10).SetObserver(rec)
+
+	s, err := ctx.HashEncoded([]byte("somepassword"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.VerifyEncoded(s, []byte("somepassword"))
+	ctx.VerifyEncoded(s, []byte("wrongpassword"))
+	ctx.VerifyEncoded("$argon2id$garbage", []byte("somepassword"))
+	ctx.Hash([]byte("somepassword"), []byte("s"))
+
+	want := []struct {
+		op      string
+		outcome Outcome
+	}{
+		{OpHash, OutcomeOK},
+		{OpVerifyEncoded, OutcomeMatch},
+		{OpVerifyEncoded, OutcomeMismatch},
+		{OpVerifyEncoded, OutcomeError},
+		{OpHash, OutcomeError},
+	}
+	if len(rec.seen) != len(want) {
+		t.Fatalf("got %d observations want %d: %+v", len(rec.seen), len(want), rec.seen)
+	}
+	for i, w := range want {
+		o := rec.seen[i]
+		if o.Op != w.op || o.Outcome != w.outcome {
+			t.Errorf("%d: got %s/%s want %s/%s", i, o.Op, o.Outcome, w.op, w.outcome)
+		}
+	}
+	if rec.seen[0].Params.Memory != 1<<10 || rec.seen[0].Compute <= 0 {
+		t.Errorf("observation missing params or timing: %+v", rec.seen[0])
+	}
+}
+
+func TestDefaultObserver(t *testing.T) {
+	rec := &recordingObserver{}
+	SetDefaultObserver(rec)
+	defer SetDefaultObserver(nil)
+
+	ctx := NewContext().SetMemory(1 << 10)
+	hash, err := ctx.Hash([]byte("somepassword"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Verify(hash, []byte("somepassword"), []byte("somesalt"))
+
+	if len(rec.seen) != 2 || rec.seen[1].Op != OpVerify || rec.seen[1].Outcome != OutcomeMatch {
+		t.Errorf("unexpected observations %+v", rec.seen)
+	}
+}
@@ -0,0 +1,136 @@
+package argon2_go_withsecret
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrPasswordHashParams = errors.New("argon2-go-withsecret: encoded hash parameters out of range")
+	ErrPasswordHashScan   = errors.New("argon2-go-withsecret: cannot scan encoded hash from this type")
+)
+
+// PasswordHash is a parsed encoded hash that can be stored directly in ORM models.
+// It marshals to and from the encoded string as text, JSON and SQL, validating on the way in.
+// The encoding is kept exactly as parsed so hints, legacy forms and format prefixes round trip unchanged.
+// The zero PasswordHash is stored as NULL in SQL, null in JSON and empty text.
+type PasswordHash struct {
+	Params  Params
+	Salt    []byte
+	Hash    []byte
+	encoded string
+}
+
+// ParsePasswordHash parses and validates an encoding in any form accepted by VerifyEncoded.
+func ParsePasswordHash(encoded string) (*PasswordHash, error) {
+	ph := &PasswordHash{}
+	err := ph.parse(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return ph, nil
+}
+
+func (ph *PasswordHash) parse(encoded string) error {
+	ctx := NewContext()
+	hash, salt, err := ctx.setFromEncoded(encoded)
+	if err != nil {
+		return err
+	}
+	if len(hash) == 0 || len(salt) == 0 {
+		return ErrEncodedFormat
+	}
+	params := ctx.GetParams()
+	params.HashLen = len(hash)
+	if params.Iterations < 1 || params.Parallelism < 1 || params.Memory < 8*params.Parallelism ||
+		(params.Version != Version10 && params.Version != Version13) {
+		return ErrPasswordHashParams
+	}
+	*ph = PasswordHash{Params: params, Salt: salt, Hash: hash, encoded: encoded}
+	return nil
+}
+
+// HashPassword is HashEncoded returning a PasswordHash.
+// HashEncoded itself keeps returning a string so that existing callers do not break.
+func (ctx *Context) HashPassword(password []byte, salt []byte) (*PasswordHash, error) {
+	s, err := ctx.HashEncoded(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePasswordHash(s)
+}
+
+// VerifyPasswordHash is VerifyEncoded for a PasswordHash.
+func (ctx *Context) VerifyPasswordHash(ph *PasswordHash, password []byte) (bool, error) {
+	if ph == nil || ph.IsZero() {
+		return false, ErrHash
+	}
+	return ctx.VerifyEncoded(ph.encoded, password)
+}
+
+// IsZero reports whether ph holds no hash.
+func (ph PasswordHash) IsZero() bool {
+	return ph.encoded == ""
+}
+
+// String returns the encoding.
+func (ph PasswordHash) String() string {
+	return ph.encoded
+}
+
+func (ph PasswordHash) MarshalText() ([]byte, error) {
+	return []byte(ph.encoded), nil
+}
+
+// UnmarshalText accepts the empty text written by MarshalText for the zero PasswordHash.
+func (ph *PasswordHash) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*ph = PasswordHash{}
+		return nil
+	}
+	return ph.parse(string(text))
+}
+
+func (ph PasswordHash) MarshalJSON() ([]byte, error) {
+	if ph.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(ph.encoded)
+}
+
+func (ph *PasswordHash) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*ph = PasswordHash{}
+		return nil
+	}
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	return ph.parse(s)
+}
+
+// Scan implements sql.Scanner. NULL gives the zero PasswordHash, anything else must be a valid encoding.
+func (ph *PasswordHash) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*ph = PasswordHash{}
+		return nil
+	case string:
+		return ph.parse(v)
+	case []byte:
+		return ph.parse(string(v))
+	default:
+		return ErrPasswordHashScan
+	}
+}
+
+// Value implements driver.Valuer.
+func (ph PasswordHash) Value() (driver.Value, error) {
+	if ph.IsZero() {
+		return nil, nil
+	}
+	return ph.encoded, nil
+}
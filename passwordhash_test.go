@@ -0,0 +1,112 @@
+package argon2_go_withsecret
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"testing"
+)
+
+var (
+	_ encoding.TextMarshaler   = PasswordHash{}
+	_ encoding.TextUnmarshaler = &PasswordHash{}
+	_ json.Marshaler           = PasswordHash{}
+	_ json.Unmarshaler         = &PasswordHash{}
+	_ sql.Scanner              = &PasswordHash{}
+	_ driver.Valuer            = PasswordHash{}
+)
+
+func TestPasswordHash(t *testing.T) {
+	ph, err := ParsePasswordHash(refArgon2id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Params{Mode: ModeArgon2id, Version: Version13, Memory: 65536, Iterations: 2, Parallelism: 1, HashLen: 32}
+	if ph.Params != want || !bytes.Equal(ph.Salt, []byte("somesalt")) || len(ph.Hash) != 32 {
+		t.Errorf("got %+v", ph)
+	}
+	if ph.String() != refArgon2id {
+		t.Errorf("String() = %q", ph.String())
+	}
+
+	type user struct {
+		Name string
+		Hash PasswordHash
+	}
+	b, err := json.Marshal(user{"alice", *ph})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"Name":"alice","Hash":"`+refArgon2id+`"}` {
+		t.Errorf("json: %s", b)
+	}
+	var u user
+	if err = json.Unmarshal(b, &u); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := NewContext().VerifyPasswordHash(&u.Hash, []byte("password"))
+	if !ok || err != nil {
+		t.Errorf("VerifyPasswordHash = %v, %v  want true", ok, err)
+	}
+
+	v, err := ph.Value()
+	if v != refArgon2id || err != nil {
+		t.Errorf("Value() = %v, %v", v, err)
+	}
+	var scanned PasswordHash
+	if err = scanned.Scan([]byte("{ARGON2}" + refArgon2id)); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ = scanned.Value(); v != "{ARGON2}"+refArgon2id {
+		t.Errorf("prefix not preserved: %v", v)
+	}
+	if err = scanned.Scan(nil); err != nil || !scanned.IsZero() {
+		t.Errorf("Scan(nil) = %v", err)
+	}
+	if v, _ = scanned.Value(); v != nil {
+		t.Errorf("zero Value() = %v  want nil", v)
+	}
+}
+
+func TestPasswordHashValidation(t *testing.T) {
+	vectors := []struct {
+		src interface{}
+		err error
+	}{
+		{"not a hash", ErrEncodedFormatNotSixParts},
+		{"$argon2id$v=19$m=4,t=2,p=1$c29tZXNhbHQ$aGFzaGhhc2g", ErrPasswordHashParams},
+		{"$argon2id$v=7$m=65536,t=2,p=1$c29tZXNhbHQ$aGFzaGhhc2g", ErrPasswordHashParams},
+		{"$argon2id$v=19$m=65536,t=0,p=1$c29tZXNhbHQ$aGFzaGhhc2g", ErrPasswordHashParams},
+		{"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$", ErrEncodedFormat},
+		{42, ErrPasswordHashScan},
+	}
+	for i, v := range vectors {
+		var ph PasswordHash
+		err := ph.Scan(v.src)
+		if err != v.err {
+			t.Errorf("%d: got %v  want %v", i, err, v.err)
+		}
+	}
+
+	// a bad salt is not hidden by a good hash
+	var ph PasswordHash
+	if err := ph.Scan("$argon2id$v=19$m=65536,t=2,p=1$c29t!ZXNhbHQ$aGFzaGhhc2g"); err == nil {
+		t.Errorf("bad salt scanned as %+v", ph)
+	}
+}
+
+func TestPasswordHashZero(t *testing.T) {
+	text, err := PasswordHash{}.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ph, err := ParsePasswordHash(refArgon2id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ph.UnmarshalText(text); err != nil || !ph.IsZero() {
+		t.Errorf("UnmarshalText(%q) = %v, %+v  want the zero PasswordHash", text, err, ph)
+	}
+}
@@ -0,0 +1,220 @@
+package argon2_go_withsecret
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// password policy rules reported in a Violation
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleBanned    = "banned"
+	RuleContext   = "context"
+	RuleEntropy   = "entropy"
+	RuleBreached  = "breached"
+)
+
+// contextWordMin is the shortest context word checked, so initials do not ban half the alphabet.
+const contextWordMin = 3
+
+var ErrPasswordPolicy = errors.New("argon2-go-withsecret: password does not meet policy")
+
+// commonPasswords are always banned, see PasswordPolicy.LoadBanned for a real list.
+var commonPasswords = []string{
+	"password", "password1", "passw0rd", "123456", "12345678", "123456789", "1234567890", "111111", "qwerty",
+	"qwertyuiop", "abc123", "letmein", "iloveyou", "admin", "welcome", "monkey", "dragon", "football",
+	"baseball", "sunshine", "princess", "trustno1",
+}
+
+// Violation is one way in which a password fails a PasswordPolicy.
+type Violation struct {
+	Rule   string // one of the Rule constants
+	Detail string // for the user, never containing the password
+}
+
+// PasswordViolations is the error returned for a password that fails a PasswordPolicy.
+// errors.Is(err, ErrPasswordPolicy) reports whether err is one.
+type PasswordViolations []Violation
+
+func (v PasswordViolations) Error() string {
+	rules := make([]string, len(v))
+	for i := range v {
+		rules[i] = v[i].Rule
+	}
+	return ErrPasswordPolicy.Error() + ": " + strings.Join(rules, ", ")
+}
+
+func (v PasswordViolations) Unwrap() error {
+	return ErrPasswordPolicy
+}
+
+// PasswordPolicy checks new passwords along the lines of NIST SP 800-63B: a minimum and maximum length in
+// characters, with all of Unicode allowed and no composition rules, a list of banned passwords, and words from the
+// context such as the user name, and of passwords known from breaches. An entropy estimate can be required in addition.
+// Set it with SetPasswordPolicy to enforce it in HashEncoded. It is never applied when verifying.
+type PasswordPolicy struct {
+	MinRunes   int           // minimum length in characters, NIST asks for at least 8
+	MaxRunes   int           // maximum length in characters, NIST asks to allow at least 64; bounds hashing work
+	MinEntropy float64       // minimum estimated bits, 0 to disable
+	Breaches   BreachChecker // passwords known from breaches, such as a BreachCorpus or BloomFilter
+	banned     map[string]bool
+}
+
+// NewPasswordPolicy returns a policy of 8 to 64 characters that bans the most common passwords.
+func NewPasswordPolicy() *PasswordPolicy {
+	pp := &PasswordPolicy{MinRunes: 8, MaxRunes: 64, banned: map[string]bool{}}
+	pp.Ban(commonPasswords...)
+	return pp
+}
+
+// Ban adds passwords to the banned list. Comparison ignores case.
+func (pp *PasswordPolicy) Ban(passwords ...string) {
+	if pp.banned == nil {
+		pp.banned = map[string]bool{}
+	}
+	for _, p := range passwords {
+		pp.banned[strings.ToLower(p)] = true
+	}
+}
+
+// LoadBanned adds the passwords in a file, one per line, to the banned list. Blank lines are ignored.
+func (pp *PasswordPolicy) LoadBanned(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimRight(sc.Text(), "\r"); line != "" {
+			pp.Ban(line)
+		}
+	}
+	return sc.Err()
+}
+
+// CheckPassword returns the ways in which password fails the policy, or nil.
+// context holds values such as the user name, email address or service name; the words in them may not
+// appear in the password.
+func (pp *PasswordPolicy) CheckPassword(password []byte, context ...string) PasswordViolations {
+	var v PasswordViolations
+	n := utf8.RuneCount(password)
+	if n < pp.MinRunes {
+		v = append(v, Violation{RuleMinLength, fmt.Sprintf("must be at least %d characters", pp.MinRunes)})
+	}
+	if pp.MaxRunes > 0 && n > pp.MaxRunes {
+		v = append(v, Violation{RuleMaxLength, fmt.Sprintf("must be at most %d characters", pp.MaxRunes)})
+		// do not spend more time on an oversized password
+		return v
+	}
+	lower := strings.ToLower(string(password))
+	if pp.banned[lower] {
+		v = append(v, Violation{RuleBanned, "is a commonly used password"})
+	}
+	for _, word := range contextWords(context) {
+		if strings.Contains(lower, word) {
+			v = append(v, Violation{RuleContext, "contains your name or other account details"})
+			break
+		}
+	}
+	if pp.MinEntropy > 0 && EstimateEntropy(password) < pp.MinEntropy {
+		v = append(v, Violation{RuleEntropy, "is too easy to guess"})
+	}
+	if pp.Breaches != nil {
+		// fail closed: a password that cannot be checked is not accepted
+		breached, err := pp.Breaches.Breached(password)
+		if err != nil {
+			v = append(v, Violation{RuleBreached, "could not be checked against breached passwords, try again later"})
+		} else if breached {
+			v = append(v, Violation{RuleBreached, "has appeared in a data breach"})
+		}
+	}
+	return v
+}
+
+// contextWords splits context values into lower case words of letters and digits.
+func contextWords(context []string) []string {
+	var words []string
+	for _, c := range context {
+		for _, w := range strings.FieldsFunc(strings.ToLower(c), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if utf8.RuneCountInString(w) >= contextWordMin {
+				words = append(words, w)
+			}
+		}
+	}
+	return words
+}
+
+// EstimateEntropy returns a rough estimate in bits of the strength of password: each character is worth the bits
+// of the character classes used, except that a character repeating or continuing a sequence from the previous one,
+// as in "aaaa" or "1234", is worth one bit. It is an upper bound for passwords made of words.
+func EstimateEntropy(password []byte) float64 {
+	var lower, upper, digit, other, nonASCII bool
+	for _, r := range string(password) {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf:
+			other = true
+		default:
+			nonASCII = true
+		}
+	}
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {other, 33}, {nonASCII, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+	perRune := math.Log2(float64(pool))
+	var bits float64
+	prev := rune(-10)
+	for _, r := range string(password) {
+		if d := r - prev; d >= -1 && d <= 1 {
+			bits++
+		} else {
+			bits += perRune
+		}
+		prev = r
+	}
+	return bits
+}
+
+// sets Context fields. HashEncoded, and the methods built on it, refuse passwords that fail pp with
+// PasswordViolations before hashing. HashEncodedFor also checks the account ID as context; other context words
+// need a call to CheckPassword. nil disables the check. Replacements made by a MultiVerifier are not checked,
+// so that users with weak passwords still move off legacy hashes; ask them for a new password separately.
+func (ctx *Context) SetPasswordPolicy(pp *PasswordPolicy) *Context {
+	ctx.passwordPolicy = pp
+	return ctx
+}
+
+// checkPasswordPolicy applies the Context password policy, if any.
+func (ctx *Context) checkPasswordPolicy(password []byte, context ...string) error {
+	if ctx.passwordPolicy == nil {
+		return nil
+	}
+	if v := ctx.passwordPolicy.CheckPassword(password, context...); v != nil {
+		return v
+	}
+	return nil
+}
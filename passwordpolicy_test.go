@@ -0,0 +1,111 @@
+package argon2_go_withsecret
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPassword(t *testing.T) {
+	pp := NewPasswordPolicy()
+	pp.MinEntropy = 40
+	vectors := []struct {
+		password string
+		context  []string
+		rules    []string
+	}{
+		{"correct horse battery staple", nil, nil},
+		{"short", nil, []string{RuleMinLength, RuleEntropy}},
+		{strings.Repeat("x", 65), nil, []string{RuleMaxLength}},
+		{"PassWord1", nil, []string{RuleBanned}},
+		{"alice-in-chains-99", []string{"alice.smith@example.com"}, []string{RuleContext}},
+		{"an example of nothing", []string{"alice.smith@example.com"}, []string{RuleContext}},
+		{"al is my friend x", []string{"al"}, nil},
+		{"aaaaaaaaaaaaaaaa", nil, []string{RuleEntropy}},
+		{"abcdefghijklmnop", nil, []string{RuleEntropy}},
+		{"пароль-не-ascii", nil, nil},
+		{"日本語のパスワード", nil, nil},
+	}
+	for _, v := range vectors {
+		got := pp.CheckPassword([]byte(v.password), v.context...)
+		var rules []string
+		for _, violation := range got {
+			rules = append(rules, violation.Rule)
+			if strings.Contains(violation.Detail, v.password) {
+				t.Errorf("%q: detail leaks password", v.password)
+			}
+		}
+		if strings.Join(rules, ",") != strings.Join(v.rules, ",") {
+			t.Errorf("%q: got %v  want %v", v.password, rules, v.rules)
+		}
+	}
+}
+
+func TestLoadBanned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	os.WriteFile(path, []byte("hunter2hunter2\r\n\nTr0ub4dor&3\n"), 0600)
+	pp := NewPasswordPolicy()
+	if err := pp.LoadBanned(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, pw := range []string{"hunter2hunter2", "tr0ub4dor&3"} {
+		if v := pp.CheckPassword([]byte(pw)); len(v) != 1 || v[0].Rule != RuleBanned {
+			t.Errorf("%s: got %v", pw, v)
+		}
+	}
+}
+
+func TestHashEncodedPasswordPolicy(t *testing.T) {
+	ctx := NewContext().SetMemory(1 << 10).SetPasswordPolicy(NewPasswordPolicy())
+	_, err := ctx.HashEncoded([]byte("123456"), []byte("somesalt"))
+	var v PasswordViolations
+	if !errors.Is(err, ErrPasswordPolicy) || !errors.As(err, &v) || len(v) != 2 {
+		t.Errorf("123456: got %v", err)
+	}
+	_, err = ctx.HashEncodedFor("bob.jones", []byte("bobjones-secret"))
+	if !errors.Is(err, ErrPasswordPolicy) {
+		t.Errorf("account word: got %v", err)
+	}
+	s, err := ctx.HashEncodedFor("bob.jones", []byte("correct horse battery staple"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// existing hashes of weak passwords still verify
+	weak, _ := NewContext().SetMemory(1<<10).HashEncoded([]byte("123456"), []byte("somesalt"))
+	if ok, err := ctx.VerifyEncoded(weak, []byte("123456")); !ok || err != nil {
+		t.Errorf("verify weak: got %v, %v", ok, err)
+	}
+	if ok, err := ctx.VerifyEncodedFor("bob.jones", s, []byte("correct horse battery staple")); !ok || err != nil {
+		t.Errorf("verify: got %v, %v", ok, err)
+	}
+}
+
+func TestMultiVerifierPasswordPolicy(t *testing.T) {
+	ctx := NewContext().SetMemory(1 << 10).SetPasswordPolicy(NewPasswordPolicy())
+	bc, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := ctx.WrapLegacy(WrapBcrypt, string(bc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mv := NewMultiVerifier(ctx)
+	for _, legacy := range []string{string(bc), wrapped} {
+		ok, replacement, err := mv.Verify(legacy, []byte("123456"))
+		if !ok || replacement == "" || err != nil {
+			t.Fatalf("%s: got %v, %q, %v  want a replacement", legacy, ok, replacement, err)
+		}
+		ok, again, err := mv.Verify(replacement, []byte("123456"))
+		if !ok || again != "" || err != nil {
+			t.Errorf("replacement: got %v, %q, %v", ok, again, err)
+		}
+	}
+	if _, err := ctx.HashEncodedRandomSalt([]byte("123456")); !errors.Is(err, ErrPasswordPolicy) {
+		t.Errorf("MultiVerifier changed the Context policy: got %v", err)
+	}
+}
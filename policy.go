@@ -0,0 +1,250 @@
+package argon2_go_withsecret
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// policyEnvPrefix is prepended to the upper cased policy keys to name environment variables, as in ARGON2_MEMORY.
+const policyEnvPrefix = "ARGON2_"
+
+var ErrPolicy = errors.New("argon2-go-withsecret: invalid policy")
+
+// Policy declares how passwords are hashed and which encodings are verified, so that operators can tune
+// the parameters without recompiling. It can be loaded from JSON, a YAML subset or ARGON2_* environment variables
+// using the keys in the json tags; keys that are not given keep the NewContext defaults.
+// Secrets are never part of a Policy, only the path of a key ring file.
+type Policy struct {
+	Mode           string `json:"mode"`    // argon2d, argon2i or argon2id
+	Version        int    `json:"version"` // 16 or 19, as written in v=
+	Memory         int    `json:"memory"`  // KiB
+	Iterations     int    `json:"iterations"`
+	Parallelism    int    `json:"parallelism"`
+	HashLen        int    `json:"hash_len"`
+	SaltLen        int    `json:"salt_len"`
+	MaxMemory      int    `json:"max_memory"` // verify limits, 0 for none, see SetVerifyLimits
+	MaxIterations  int    `json:"max_iterations"`
+	MaxParallelism int    `json:"max_parallelism"`
+	KeyRingFile    string `json:"key_ring_file"` // see LoadKeyRing, relative to the policy file
+
+	keyRing *KeyRing // loaded by Validate
+}
+
+// DefaultPolicy returns the policy of NewContext.
+func DefaultPolicy() *Policy {
+	ctx := NewContext()
+	return &Policy{
+		Mode:        argon2_type2string(ctx.GetMode()),
+		Version:     ctx.GetVersion(),
+		Memory:      ctx.GetMemory(),
+		Iterations:  ctx.GetIterations(),
+		Parallelism: ctx.GetParallelism(),
+		HashLen:     ctx.GetHashLen(),
+		SaltLen:     DefaultSaltLen,
+	}
+}
+
+// LoadPolicy reads a .json, .yaml or .yml policy file and validates it.
+// A relative KeyRingFile is taken relative to the directory of the policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := DefaultPolicy()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = p.decodeJSON(data)
+	case ".yaml", ".yml":
+		err = p.decodeYAML(data)
+	default:
+		err = fmt.Errorf("%w: unknown file type %q", ErrPolicy, filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.KeyRingFile != "" && !filepath.IsAbs(p.KeyRingFile) {
+		p.KeyRingFile = filepath.Join(filepath.Dir(path), p.KeyRingFile)
+	}
+	return p, p.Validate()
+}
+
+// ParsePolicyJSON parses and validates a JSON policy. Unknown keys are errors.
+func ParsePolicyJSON(data []byte) (*Policy, error) {
+	p := DefaultPolicy()
+	err := p.decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return p, p.Validate()
+}
+
+// ParsePolicyYAML parses and validates a policy written in the subset of YAML made of "key: value" lines,
+// with optional quotes and # comments. Unknown keys are errors.
+func ParsePolicyYAML(data []byte) (*Policy, error) {
+	p := DefaultPolicy()
+	err := p.decodeYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	return p, p.Validate()
+}
+
+// PolicyFromEnv returns the default policy overridden by ARGON2_* environment variables, see ApplyEnv.
+func PolicyFromEnv() (*Policy, error) {
+	p := DefaultPolicy()
+	return p, p.ApplyEnv()
+}
+
+// ApplyEnv overrides p with the environment variables named by ARGON2_ and the upper cased key,
+// for example ARGON2_MEMORY=262144 or ARGON2_KEY_RING_FILE=/run/secrets/argon2, then validates it.
+func (p *Policy) ApplyEnv() error {
+	for key := range p.fields() {
+		value, found := os.LookupEnv(policyEnvPrefix + strings.ToUpper(key))
+		if !found {
+			continue
+		}
+		err := p.set(key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return p.Validate()
+}
+
+func (p *Policy) decodeJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(p)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPolicy, err)
+	}
+	return nil
+}
+
+func (p *Policy) decodeYAML(data []byte) error {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line == "---" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return fmt.Errorf("%w: line %d is not key: value", ErrPolicy, n)
+		}
+		value = strings.TrimSpace(value)
+		if q := value[:min(1, len(value))]; q == `"` || q == "'" {
+			end := strings.Index(value[1:], q)
+			if end < 0 {
+				return fmt.Errorf("%w: line %d has an unterminated quote", ErrPolicy, n)
+			}
+			value = value[1 : end+1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		err := p.set(strings.TrimSpace(key), value)
+		if err != nil {
+			return fmt.Errorf("%w (line %d)", err, n)
+		}
+	}
+	return sc.Err()
+}
+
+// fields maps the policy keys to the Policy fields.
+func (p *Policy) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"mode":            &p.Mode,
+		"version":         &p.Version,
+		"memory":          &p.Memory,
+		"iterations":      &p.Iterations,
+		"parallelism":     &p.Parallelism,
+		"hash_len":        &p.HashLen,
+		"salt_len":        &p.SaltLen,
+		"max_memory":      &p.MaxMemory,
+		"max_iterations":  &p.MaxIterations,
+		"max_parallelism": &p.MaxParallelism,
+		"key_ring_file":   &p.KeyRingFile,
+	}
+}
+
+// set sets the field for key from its string form. Numbers may be decimal or 0x hexadecimal.
+func (p *Policy) set(key string, value string) error {
+	switch f := p.fields()[key].(type) {
+	case *string:
+		*f = value
+	case *int:
+		n, err := strconv.ParseInt(value, 0, 0)
+		if err != nil {
+			return fmt.Errorf("%w: %s %q is not a number", ErrPolicy, key, value)
+		}
+		*f = int(n)
+	default:
+		return fmt.Errorf("%w: unknown key %q", ErrPolicy, key)
+	}
+	return nil
+}
+
+// Validate checks the parameters against the limits of Argon2 and loads the key ring file, if any.
+func (p *Policy) Validate() error {
+	if _, err := argon2_string2type(p.Mode); err != nil {
+		return fmt.Errorf("%w: unknown mode %q", ErrPolicy, p.Mode)
+	}
+	var problem string
+	switch {
+	case p.Version != Version10 && p.Version != Version13:
+		problem = "version must be 16 or 19"
+	case p.Iterations < 1:
+		problem = "iterations must be at least 1"
+	case p.Parallelism < 1 || p.Parallelism > 0xFFFFFF:
+		problem = "parallelism must be between 1 and 2^24-1"
+	case p.Memory < 8*p.Parallelism:
+		problem = "memory must be at least 8 KiB per lane"
+	case p.HashLen < 4:
+		problem = "hash_len must be at least 4"
+	case p.SaltLen < minSaltLen:
+		problem = "salt_len must be at least 8"
+	case p.MaxMemory < 0 || p.MaxIterations < 0 || p.MaxParallelism < 0:
+		problem = "verify limits cannot be negative"
+	case (p.MaxMemory > 0 && p.MaxMemory < p.Memory) || (p.MaxIterations > 0 && p.MaxIterations < p.Iterations) ||
+		(p.MaxParallelism > 0 && p.MaxParallelism < p.Parallelism):
+		problem = "verify limits are below the hashing parameters"
+	}
+	if problem != "" {
+		return fmt.Errorf("%w: %s", ErrPolicy, problem)
+	}
+	p.keyRing = nil
+	if p.KeyRingFile != "" {
+		kr, err := LoadKeyRing(p.KeyRingFile)
+		if err != nil {
+			return fmt.Errorf("%w: key_ring_file: %w", ErrPolicy, err)
+		}
+		p.keyRing = kr
+	}
+	return nil
+}
+
+// NewContext returns a Context hashing with the policy parameters and salt length, verifying within its limits
+// and using its key ring. Call Validate first if p was not loaded by this package.
+func (p *Policy) NewContext() *Context {
+	mode, _ := argon2_string2type(p.Mode)
+	ctx := NewContext(mode).SetVersion(p.Version).SetMemory(p.Memory).SetIterations(p.Iterations).
+		SetParallelism(p.Parallelism).SetHashLen(p.HashLen).
+		SetVerifyLimits(p.MaxMemory, p.MaxIterations, p.MaxParallelism).SetSaltGenerator(&SaltGenerator{Len: p.SaltLen})
+	if p.keyRing != nil {
+		ctx.SetKeyRing(p.keyRing)
+	}
+	return ctx
+}
+
+// NewSalt returns a random salt of the policy length.
+func (p *Policy) NewSalt() ([]byte, error) {
+	return (&SaltGenerator{Len: p.SaltLen}).NewSalt()
+}
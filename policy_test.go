@@ -0,0 +1,124 @@
+package argon2_go_withsecret
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	p := DefaultPolicy()
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.NewContext().GetParams(), NewContext().GetParams(); got != want {
+		t.Errorf("got %+v  want %+v", got, want)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	want := Policy{Mode: "argon2i", Version: Version13, Memory: 1 << 10, Iterations: 2, Parallelism: 1,
+		HashLen: 64, SaltLen: 32, MaxMemory: 1 << 12}
+
+	p, err := ParsePolicyJSON([]byte(`{"mode": "argon2i", "memory": 1024, "iterations": 2, "parallelism": 1,
+		"hash_len": 64, "salt_len": 32, "max_memory": 4096}`))
+	if err != nil || *p != want {
+		t.Errorf("json: got %+v, %v", p, err)
+	}
+
+	p, err = ParsePolicyYAML([]byte(`---
+# login hashing
+mode: "argon2i"
+memory: 1024   # KiB
+iterations: 2
+parallelism: 1
+hash_len: 64
+salt_len: 32
+max_memory: 0x1000
+`))
+	if err != nil || *p != want {
+		t.Errorf("yaml: got %+v, %v", p, err)
+	}
+
+	ctx := p.NewContext()
+	if got := ctx.GetParams(); got != (Params{ModeArgon2i, Version13, 1 << 10, 2, 1, 64}) {
+		t.Errorf("context: got %+v", got)
+	}
+	s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := NewContext().VerifyEncoded(s, []byte("password"))
+	if !ok || err != nil {
+		t.Errorf("64 byte hash: got %v, %v  want true", ok, err)
+	}
+	_, err = ctx.VerifyEncoded(refArgon2i, []byte("password"))
+	if err != ErrVerifyLimit {
+		t.Errorf("m=65536 beyond max_memory: got %v  want %v", err, ErrVerifyLimit)
+	}
+
+	for _, bad := range []string{
+		`{"mode": "argon2x"}`,
+		`{"version": 18}`,
+		`{"memory": 8, "parallelism": 2}`,
+		`{"salt_len": 4}`,
+		`{"max_iterations": 1}`,
+		`{"secret": "nope"}`,
+		`{"key_ring_file": "/nonexistent"}`,
+	} {
+		_, err = ParsePolicyJSON([]byte(bad))
+		if !errors.Is(err, ErrPolicy) {
+			t.Errorf("%s: got %v  want %v", bad, err, ErrPolicy)
+		}
+	}
+	for _, bad := range []string{"memory 1024", "memory: lots", "colour: blue", `mode: "argon2i`} {
+		_, err = ParsePolicyYAML([]byte(bad))
+		if !errors.Is(err, ErrPolicy) {
+			t.Errorf("%s: got %v  want %v", bad, err, ErrPolicy)
+		}
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "keyring"), []byte("bmV3bmV3bmV3bmV3bmV3bmV3bmV3bmV3\n"), 0600)
+	path := filepath.Join(dir, "argon2.yaml")
+	os.WriteFile(path, []byte("memory: 1024\nkey_ring_file: keyring\n"), 0600)
+
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := p.NewContext()
+	if string(ctx.Secret) != "newnewnewnewnewnewnewnew" || !ctx.encodeHints {
+		t.Errorf("key ring not applied: %v", ctx)
+	}
+	salt, err := p.NewSalt()
+	if err != nil || len(salt) != 16 {
+		t.Errorf("salt: got %d bytes, %v", len(salt), err)
+	}
+
+	_, err = LoadPolicy(filepath.Join(dir, "argon2.toml"))
+	if err == nil {
+		t.Error("toml: got nil error")
+	}
+}
+
+func TestPolicyFromEnv(t *testing.T) {
+	t.Setenv("ARGON2_MEMORY", "2048")
+	t.Setenv("ARGON2_MODE", "argon2d")
+	p, err := PolicyFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Memory != 2048 || p.Mode != "argon2d" || p.Iterations != NewContext().GetIterations() {
+		t.Errorf("got %+v", p)
+	}
+
+	t.Setenv("ARGON2_ITERATIONS", "0")
+	_, err = PolicyFromEnv()
+	if !errors.Is(err, ErrPolicy) {
+		t.Errorf("got %v  want %v", err, ErrPolicy)
+	}
+}
@@ -0,0 +1,48 @@
+package argon2_go_withsecret
+
+import (
+	"golang.org/x/crypto/blake2b"
+)
+
+// preHashBLAKE2b is the pre= value of encodings whose password was pre-hashed with BLAKE2b.
+const preHashBLAKE2b = "b2b"
+
+// sets Context fields. When on, HashEncoded replaces the password by its BLAKE2b-512 hash, keyed with the secret
+// if there is one, before Argon2 sees it. Passwords of any length, long passphrases or the contents of key files
+// then cost the same 64 bytes under the package mutex. The encoding records this as pre=b2b and VerifyEncoded
+// pre-hashes the same way, after any normalization. Hash and Verify, which have no encoding, are not affected.
+func (ctx *Context) SetPreHash(on bool) *Context {
+	ctx.preHash = on
+	return ctx
+}
+
+// preHashPassword returns the pre-hash of password if the Context asks for one, see SetPreHash.
+// With FlagClearPassword password is cleared, as libargon2 only clears the pre-hash.
+func (ctx *Context) preHashPassword(password []byte) []byte {
+	if !ctx.preHash {
+		return password
+	}
+	key := ctx.Secret
+	if len(key) > blake2b.Size {
+		// BLAKE2b keys are at most 64 bytes
+		sum := blake2b.Sum512(key)
+		key = sum[:]
+	}
+	h, _ := blake2b.New512(key)
+	h.Write(password)
+	if ctx.Flags&FlagClearPassword != 0 {
+		for i := range password {
+			password[i] = 0
+		}
+	}
+	return h.Sum(nil)
+}
+
+// preparePassword applies the normalization and pre-hash of the last encoding to a password being verified.
+func (ctx *Context) preparePassword(password []byte) ([]byte, error) {
+	password, err := ctx.normalizePassword(password)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.preHashPassword(password), nil
+}
@@ -0,0 +1,57 @@
+package argon2_go_withsecret
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPreHash(t *testing.T) {
+	keyFile := bytes.Repeat([]byte("0123456789abcdef"), 1<<16) // 1 MiB
+	secret := bytes.Repeat([]byte("s"), 100)                   // longer than a BLAKE2b key
+	for _, sec := range [][]byte{nil, []byte("somesecret"), secret} {
+		ctx := NewContext().SetMemory(1 << 10).SetSecret(sec).SetPreHash(true)
+		s, err := ctx.HashEncoded(keyFile, []byte("somesalt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(s, ",pre=b2b$") {
+			t.Errorf("not recorded in %s", s)
+		}
+		ctx4v := NewContext().SetSecret(sec)
+		if ok, err := ctx4v.VerifyEncoded(s, keyFile); !ok || err != nil {
+			t.Errorf("secret %d bytes: got %v, %v  want true", len(sec), ok, err)
+		}
+		if ok, _ := ctx4v.VerifyEncoded(s, keyFile[1:]); ok {
+			t.Errorf("secret %d bytes: other key file matched", len(sec))
+		}
+		if sec != nil {
+			if ok, _ := NewContext().SetSecret([]byte("othersecret")).VerifyEncoded(s, keyFile); ok {
+				t.Errorf("secret %d bytes: matched with another secret", len(sec))
+			}
+		}
+
+		ph, _ := ParsePasswordHash(s)
+		var back PasswordHash
+		if b, err := ph.MarshalBinary(); err != nil || back.UnmarshalBinary(b) != nil || back.String() != s {
+			t.Errorf("binary round trip: %v, %s", err, back.String())
+		}
+	}
+
+	// normalization comes first
+	ctx := NewContext().SetMemory(1<<10).SetPreHash(true).SetNormalization(NormalizeNFC, true)
+	s, err := ctx.HashEncoded([]byte("café "), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := NewContext().VerifyEncoded(s, []byte("café")); !ok || err != nil {
+		t.Errorf("normalized: got %v, %v in %s", ok, err, s)
+	}
+	if _, err := EncodeFormat(FormatNative, s); err != ErrFormatParams {
+		t.Errorf("native: got %v  want %v", err, ErrFormatParams)
+	}
+	_, err = NewContext().VerifyEncoded(strings.Replace(s, "pre=b2b", "pre=sha2", 1), []byte("café"))
+	if err != ErrEncodedFormatBadParameter {
+		t.Errorf("pre=sha2: got %v  want %v", err, ErrEncodedFormatBadParameter)
+	}
+}
@@ -0,0 +1,110 @@
+package argon2_go_withsecret
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Profile is a named set of parameters with the reasoning behind it, see NewContextFromProfile.
+type Profile struct {
+	Name      string
+	Params    Params
+	SaltLen   int
+	Rationale string
+}
+
+var (
+	ErrProfileUnknown = errors.New("argon2-go-withsecret: unknown profile")
+	ErrProfileExists  = errors.New("argon2-go-withsecret: profile already registered")
+)
+
+var (
+	profilesMutex = &sync.RWMutex{}
+	profiles      = map[string]Profile{}
+)
+
+func init() {
+	for _, p := range []Profile{
+		{"default", NewContext().GetParams(), 16,
+			"NewContext: 64 MiB and 3 passes, about 400ms on a dual core laptop, for general interactive logins."},
+		{"vault", NewVaultContext().GetParams(), 16,
+			"NewVaultContext: 256 MiB and 20 passes, several seconds, for master passwords and derived secrets."},
+		{"owasp", Params{ModeArgon2id, Version13, 19 * 1024, 2, 1, 32}, 16,
+			"OWASP Password Storage Cheat Sheet minimum: 19 MiB, 2 passes, 1 lane. " +
+				"Cheap enough for busy login servers while keeping GPU attacks memory bound."},
+		{"rfc9106-first", Params{ModeArgon2id, Version13, 2 * 1024 * 1024, 1, 4, 32}, 16,
+			"RFC 9106 first recommended option: 2 GiB, 1 pass, 4 lanes. " +
+				"Uniformly safe choice when the server can afford 2 GiB per hash."},
+		{"rfc9106-second", Params{ModeArgon2id, Version13, 64 * 1024, 3, 4, 32}, 16,
+			"RFC 9106 second recommended option: 64 MiB, 3 passes, 4 lanes, for memory constrained environments."},
+		{"low-memory", Params{ModeArgon2id, Version13, 12 * 1024, 3, 1, 32}, 16,
+			"OWASP alternative for small containers: 12 MiB, 3 passes, 1 lane. " +
+				"Extra passes trade time for memory so many concurrent logins fit in a tight memory limit."},
+		{"high-security-admin", Params{ModeArgon2id, Version13, 1024 * 1024, 4, 4, 32}, 32,
+			"1 GiB, 4 passes, 4 lanes and 32 byte salts, one to two seconds, for the few privileged accounts " +
+				"that log in rarely and whose compromise costs the most. Combine with a secret."},
+	} {
+		profiles[p.Name] = p
+	}
+}
+
+// RegisterProfile adds an organisation wide profile. Its parameters are validated as a Policy,
+// and names cannot be registered twice, so built in profiles cannot be weakened by accident.
+func RegisterProfile(p Profile) error {
+	err := p.Policy().Validate()
+	if err != nil {
+		return fmt.Errorf("profile %q: %w", p.Name, err)
+	}
+	profilesMutex.Lock()
+	defer profilesMutex.Unlock()
+	if _, found := profiles[p.Name]; found {
+		return fmt.Errorf("%w: %q", ErrProfileExists, p.Name)
+	}
+	profiles[p.Name] = p
+	return nil
+}
+
+// LookupProfile returns the profile registered as name.
+func LookupProfile(name string) (Profile, bool) {
+	profilesMutex.RLock()
+	defer profilesMutex.RUnlock()
+	p, found := profiles[name]
+	return p, found
+}
+
+// ProfileNames returns the names of the registered profiles in sorted order.
+func ProfileNames() []string {
+	profilesMutex.RLock()
+	defer profilesMutex.RUnlock()
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewContextFromProfile initializes a new Argon2 context with the parameters of a registered profile,
+// for example NewContextFromProfile("rfc9106-second").
+func NewContextFromProfile(name string) (*Context, error) {
+	p, found := LookupProfile(name)
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrProfileUnknown, name)
+	}
+	return p.Policy().NewContext(), nil
+}
+
+// Policy returns a policy with the profile parameters and no verify limits or key ring.
+func (p Profile) Policy() *Policy {
+	return &Policy{
+		Mode:        argon2_type2string(p.Params.Mode),
+		Version:     p.Params.Version,
+		Memory:      p.Params.Memory,
+		Iterations:  p.Params.Iterations,
+		Parallelism: p.Params.Parallelism,
+		HashLen:     p.Params.HashLen,
+		SaltLen:     p.SaltLen,
+	}
+}
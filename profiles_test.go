@@ -0,0 +1,67 @@
+package argon2_go_withsecret
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewContextFromProfile(t *testing.T) {
+	for _, name := range []string{"default", "vault", "owasp", "rfc9106-first", "rfc9106-second", "low-memory",
+		"high-security-admin"} {
+		p, found := LookupProfile(name)
+		if !found || p.Rationale == "" {
+			t.Fatalf("%s: missing or undocumented", name)
+		}
+		ctx, err := NewContextFromProfile(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := ctx.GetParams(); got != p.Params {
+			t.Errorf("%s: got %+v  want %+v", name, got, p.Params)
+		}
+	}
+	ctx, _ := NewContextFromProfile("default")
+	if ctx.GetParams() != NewContext().GetParams() {
+		t.Errorf("default: got %+v  want NewContext", ctx.GetParams())
+	}
+	ctx, _ = NewContextFromProfile("rfc9106-second")
+	if p := ctx.GetParams(); p.Memory != 1<<16 || p.Iterations != 3 || p.Parallelism != 4 {
+		t.Errorf("rfc9106-second: got %+v", p)
+	}
+
+	_, err := NewContextFromProfile("nope")
+	if !errors.Is(err, ErrProfileUnknown) {
+		t.Errorf("unknown: got %v  want %v", err, ErrProfileUnknown)
+	}
+}
+
+func TestRegisterProfile(t *testing.T) {
+	custom := Profile{"test-kiosk", Params{ModeArgon2id, Version13, 1 << 10, 2, 1, 32}, 16, "test only"}
+	err := RegisterProfile(custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := NewContextFromProfile("test-kiosk")
+	if err != nil || ctx.GetParams() != custom.Params {
+		t.Fatalf("got %+v, %v", ctx, err)
+	}
+	s, err := ctx.HashEncoded([]byte("password"), []byte("somesalt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := NewContext().VerifyEncoded(s, []byte("password")); !ok || err != nil {
+		t.Errorf("verify: got %v, %v", ok, err)
+	}
+
+	err = RegisterProfile(Profile{Name: "owasp", Params: custom.Params, SaltLen: 16})
+	if !errors.Is(err, ErrProfileExists) {
+		t.Errorf("owasp: got %v  want %v", err, ErrProfileExists)
+	}
+	err = RegisterProfile(Profile{Name: "test-weak", Params: Params{ModeArgon2id, Version13, 8, 0, 1, 32}, SaltLen: 16})
+	if !errors.Is(err, ErrPolicy) {
+		t.Errorf("weak: got %v  want %v", err, ErrPolicy)
+	}
+	if _, found := LookupProfile("test-weak"); found {
+		t.Error("invalid profile registered")
+	}
+}
@@ -0,0 +1,64 @@
+package argon2_go_withsecret
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// DefaultSaltLen is the salt length used when none is configured, the 128 bits recommended by RFC 9106.
+const DefaultSaltLen = 16
+
+// minSaltLen is the shortest salt libargon2 accepts.
+const minSaltLen = 8
+
+var ErrSaltLen = errors.New("argon2-go-withsecret: salt length must be at least 8")
+
+// SaltGenerator makes random salts of a configurable length from a configurable source.
+// The zero SaltGenerator makes DefaultSaltLen byte salts from crypto/rand.
+type SaltGenerator struct {
+	Len  int       // bytes, DefaultSaltLen if 0
+	Rand io.Reader // crypto/rand.Reader if nil; only tests should need another source
+}
+
+// NewSalt returns a new salt, or an error if the length is too short or the source fails.
+func (g *SaltGenerator) NewSalt() ([]byte, error) {
+	n, r := DefaultSaltLen, rand.Reader
+	if g != nil && g.Len != 0 {
+		n = g.Len
+	}
+	if g != nil && g.Rand != nil {
+		r = g.Rand
+	}
+	if n < minSaltLen {
+		return nil, ErrSaltLen
+	}
+	salt := make([]byte, n)
+	_, err := io.ReadFull(r, salt)
+	if err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// sets Context fields. The generator makes the salts of HashEncodedRandomSalt and of the other methods
+// that hash with a new random salt. Without one they use the zero SaltGenerator.
+func (ctx *Context) SetSaltGenerator(g *SaltGenerator) *Context {
+	ctx.saltGenerator = g
+	return ctx
+}
+
+// newSalt returns a new salt from the Context salt generator.
+func (ctx *Context) newSalt() ([]byte, error) {
+	return ctx.saltGenerator.NewSalt()
+}
+
+// HashEncodedRandomSalt is HashEncoded with a new salt from the Context salt generator,
+// so that a salt can never be reused by mistake.
+func (ctx *Context) HashEncodedRandomSalt(password []byte) (string, error) {
+	salt, err := ctx.newSalt()
+	if err != nil {
+		return "", err
+	}
+	return ctx.HashEncoded(password, salt)
+}
@@ -0,0 +1,58 @@
+package argon2_go_withsecret
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSaltGenerator(t *testing.T) {
+	salt, err := (&SaltGenerator{}).NewSalt()
+	if err != nil || len(salt) != DefaultSaltLen {
+		t.Errorf("zero generator: got %d bytes, %v", len(salt), err)
+	}
+	salt, err = (&SaltGenerator{Len: 32}).NewSalt()
+	if err != nil || len(salt) != 32 {
+		t.Errorf("32 bytes: got %d bytes, %v", len(salt), err)
+	}
+	_, err = (&SaltGenerator{Len: 4}).NewSalt()
+	if err != ErrSaltLen {
+		t.Errorf("4 bytes: got %v  want %v", err, ErrSaltLen)
+	}
+	salt, err = (&SaltGenerator{Rand: strings.NewReader("0123456789abcdefXX")}).NewSalt()
+	if err != nil || string(salt) != "0123456789abcdef" {
+		t.Errorf("fixed reader: got %q, %v", salt, err)
+	}
+	_, err = (&SaltGenerator{Rand: strings.NewReader("short")}).NewSalt()
+	if err == nil {
+		t.Error("exhausted reader: got nil error")
+	}
+}
+
+func TestHashEncodedRandomSalt(t *testing.T) {
+	g := &SaltGenerator{Rand: strings.NewReader("somesaltsomesalt")}
+	ctx := NewContext(ModeArgon2i).SetIterations(2).SetParallelism(1).SetSaltGenerator(g)
+	s, err := ctx.HashEncodedRandomSalt([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := ctx.HashEncoded([]byte("password"), []byte("somesaltsomesalt"))
+	if s != want {
+		t.Errorf("got %s  want %s", s, want)
+	}
+
+	ctx = NewContext().SetMemory(1 << 10).SetSaltGenerator(&SaltGenerator{Len: 32})
+	s1, err := ctx.HashEncodedRandomSalt([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, _ := ctx.HashEncodedRandomSalt([]byte("password"))
+	ph1, _ := ParsePasswordHash(s1)
+	ph2, _ := ParsePasswordHash(s2)
+	if len(ph1.Salt) != 32 || bytes.Equal(ph1.Salt, ph2.Salt) {
+		t.Errorf("salts %x and %x", ph1.Salt, ph2.Salt)
+	}
+	if ok, err := NewContext().VerifyEncoded(s1, []byte("password")); !ok || err != nil {
+		t.Errorf("verify: got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,192 @@
+package argon2_go_withsecret
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/blowfish"
+)
+
+// Kinds of legacy hash accepted by WrapLegacy
+const (
+	WrapBcrypt = "bcrypt" // $2a$, $2b$ or $2y$ modular crypt
+	WrapSSHA   = "ssha"   // {SSHA} salted SHA-1 as used by LDAP, sha1(password+salt)
+	WrapMD5    = "md5"    // unsalted MD5 as 32 hex digits
+)
+
+var (
+	ErrWrapKind   = errors.New("argon2-go-withsecret: unknown legacy hash kind")
+	ErrWrapLegacy = errors.New("argon2-go-withsecret: cannot parse legacy hash to wrap")
+)
+
+// legacyWrapper splits a legacy hash into the setting (salt and cost) kept in the wrapped encoding
+// and the value fed to Argon2, and recomputes that value from a password at login.
+type legacyWrapper struct {
+	split func(legacy string) (setting []byte, value []byte, err error)
+	value func(setting []byte, password []byte) ([]byte, error)
+}
+
+var legacyWrappers = map[string]legacyWrapper{
+	WrapBcrypt: {splitBcrypt, bcryptValue},
+	WrapSSHA:   {splitSSHA, sshaValue},
+	WrapMD5:    {splitMD5, md5Value},
+}
+
+// WrapLegacy hashes an existing legacy hash with Argon2, using the Context parameters and secret and a random salt,
+// so stored legacy hashes can be upgraded in bulk without waiting for logins.
+// The result looks like $argon2id-wrap-bcrypt$v=19$m=65536,t=3,p=2,wrap=...$salt$hash and keeps the legacy
+// salt and cost but not the legacy hash itself. VerifyEncoded accepts it, after which NeedsRehash reports true.
+func (ctx *Context) WrapLegacy(kind string, legacy string) (string, error) {
+	w, found := legacyWrappers[kind]
+	if !found {
+		return "", ErrWrapKind
+	}
+	setting, value, err := w.split(legacy)
+	if err != nil {
+		return "", err
+	}
+	salt, err := ctx.newSalt()
+	if err != nil {
+		return "", err
+	}
+	hash, err := ctx.Hash(value, salt)
+	if err != nil {
+		return "", err
+	}
+	options := ctx.encodedOptions()
+	if setting != nil {
+		options += ",wrap=" + base64.RawStdEncoding.EncodeToString(setting)
+	}
+	return ctx.encodeAs(argon2_type2string(ctx.a2ctx.Mode)+"-wrap-"+kind, options, salt, hash), nil
+}
+
+// NeedsRehash reports whether the encoding last set by SetFromEncoded or VerifyEncoded was a wrapped legacy hash,
+// or was made with a retired secret of the key ring, and should be replaced by a native HashEncoded with the current
+// secret after a successful verification.
+func (ctx *Context) NeedsRehash() bool {
+	return ctx.wrap != "" || ctx.retiredSecret()
+}
+
+// legacyStep computes the legacy hash of password for the wrapped encoding last set, under the package mutex
+// and, for bcrypt, within the verify limits.
+func (ctx *Context) legacyStep(password []byte) ([]byte, error) {
+	if ctx.wrap == WrapBcrypt {
+		cost, _, err := parseBcryptSetting(ctx.wrapSetting)
+		if err != nil {
+			return nil, err
+		}
+		err = ctx.checkBcryptCost(cost)
+		if err != nil {
+			return nil, err
+		}
+	}
+	mutex.Lock()
+	value, err := legacyWrappers[ctx.wrap].value(ctx.wrapSetting, password)
+	mutex.Unlock()
+	if err == nil && ctx.Flags&FlagClearPassword != 0 {
+		for i := range password {
+			password[i] = 0
+		}
+	}
+	return value, err
+}
+
+var bcryptEncoding = base64.NewEncoding("./ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789").WithPadding(base64.NoPadding)
+
+// bcrypt is $2b$<cost>$<22 characters of salt><31 characters of hash>. The setting is everything before the hash.
+func splitBcrypt(legacy string) ([]byte, []byte, error) {
+	if len(legacy) != 60 || !strings.HasPrefix(legacy, "$2") {
+		return nil, nil, ErrWrapLegacy
+	}
+	setting := []byte(legacy[:29])
+	if _, _, err := parseBcryptSetting(setting); err != nil {
+		return nil, nil, err
+	}
+	return setting, []byte(legacy), nil
+}
+
+func parseBcryptSetting(setting []byte) (cost int, salt []byte, err error) {
+	parts := strings.Split(string(setting), "$")
+	if len(parts) != 4 || len(parts[3]) != 22 {
+		return 0, nil, ErrWrapLegacy
+	}
+	cost, err = strconv.Atoi(parts[2])
+	if err != nil || cost < 4 || cost > 31 {
+		return 0, nil, ErrWrapLegacy
+	}
+	salt, err = bcryptEncoding.DecodeString(parts[3])
+	if err != nil {
+		return 0, nil, ErrWrapLegacy
+	}
+	return cost, salt, nil
+}
+
+// bcryptValue recomputes the full bcrypt string for password, as crypt(3) does, from the stored setting.
+func bcryptValue(setting []byte, password []byte) ([]byte, error) {
+	cost, salt, err := parseBcryptSetting(setting)
+	if err != nil {
+		return nil, err
+	}
+	if len(password) > 72 {
+		password = password[:72]
+	}
+	// like the C implementations the trailing NUL is part of the key
+	key := append(password[:len(password):len(password)], 0)
+	c, err := blowfish.NewSaltedCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < 1<<uint(cost); i++ {
+		blowfish.ExpandKey(key, c)
+		blowfish.ExpandKey(salt, c)
+	}
+	data := []byte("OrpheanBeholderScryDoubt")
+	for i := 0; i < 24; i += 8 {
+		for j := 0; j < 64; j++ {
+			c.Encrypt(data[i:i+8], data[i:i+8])
+		}
+	}
+	// only 23 of the 24 bytes are encoded
+	return append(append([]byte(nil), setting...), bcryptEncoding.EncodeToString(data[:23])...), nil
+}
+
+// {SSHA} is base64 of sha1(password+salt) followed by the salt. The setting is the salt.
+func splitSSHA(legacy string) ([]byte, []byte, error) {
+	if !strings.HasPrefix(legacy, "{SSHA}") {
+		return nil, nil, ErrWrapLegacy
+	}
+	raw, err := base64.StdEncoding.DecodeString(legacy[len("{SSHA}"):])
+	if err != nil || len(raw) <= sha1.Size {
+		return nil, nil, ErrWrapLegacy
+	}
+	return raw[sha1.Size:], raw[:sha1.Size], nil
+}
+
+func sshaValue(setting []byte, password []byte) ([]byte, error) {
+	if len(setting) == 0 {
+		return nil, ErrWrapLegacy
+	}
+	h := sha1.New()
+	h.Write(password)
+	h.Write(setting)
+	return h.Sum(nil), nil
+}
+
+// unsalted md5 has no setting.
+func splitMD5(legacy string) ([]byte, []byte, error) {
+	raw, err := hex.DecodeString(legacy)
+	if err != nil || len(raw) != md5.Size {
+		return nil, nil, ErrWrapLegacy
+	}
+	return nil, raw, nil
+}
+
+func md5Value(setting []byte, password []byte) ([]byte, error) {
+	sum := md5.Sum(password)
+	return sum[:], nil
+}
@@ -0,0 +1,101 @@
+package argon2_go_withsecret
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBcryptValue(t *testing.T) {
+	for _, pw := range []string{"password", "", strings.Repeat("x", 80)} {
+		legacy, err := bcrypt.GenerateFromPassword([]byte(pw)[:min(len(pw), 72)], bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := bcryptValue(legacy[:29], []byte(pw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, legacy) {
+			t.Errorf("got %s  want %s", got, legacy)
+		}
+	}
+}
+
+func TestWrapLegacy(t *testing.T) {
+	password := []byte("password")
+	bc, err := bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssha := sha1.Sum(append([]byte("password"), "salt"...))
+	sum := md5.Sum(password)
+
+	vectors := []struct {
+		kind   string
+		legacy string
+	}{
+		{WrapBcrypt, string(bc)},
+		{WrapSSHA, "{SSHA}" + base64.StdEncoding.EncodeToString(append(ssha[:], "salt"...))},
+		{WrapMD5, hex.EncodeToString(sum[:])},
+	}
+	ctx := NewContext().SetMemory(1 << 10).SetSecret([]byte("somesecret"))
+	for _, v := range vectors {
+		s, err := ctx.WrapLegacy(v.kind, v.legacy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(s, "$argon2id-wrap-"+v.kind+"$") || strings.Contains(s, v.legacy[len(v.legacy)-10:]) {
+			t.Errorf("unexpected wrapped encoding %s", s)
+		}
+
+		ctx4v := NewContext().SetSecret([]byte("somesecret"))
+		ok, err := ctx4v.VerifyEncoded(s, []byte("wrongpassword"))
+		if ok || err != nil {
+			t.Errorf("%s wrong password: got %v, %v", v.kind, ok, err)
+		}
+		ok, err = ctx4v.VerifyEncoded(s, []byte("password"))
+		if !ok || err != nil {
+			t.Errorf("%s: got %v, %v  want true", v.kind, ok, err)
+		}
+		if !ctx4v.NeedsRehash() {
+			t.Errorf("%s: NeedsRehash = false  want true", v.kind)
+		}
+
+		ok, replacement, err := NewMultiVerifier(ctx).Verify(s, []byte("password"))
+		if !ok || err != nil || !strings.HasPrefix(replacement, "$argon2id$v=19$") {
+			t.Errorf("%s: MultiVerifier got %v, %q, %v", v.kind, ok, replacement, err)
+		}
+	}
+
+	_, err = ctx.WrapLegacy(WrapMD5, "nothex")
+	if err != ErrWrapLegacy {
+		t.Errorf("got %v want %v", err, ErrWrapLegacy)
+	}
+	_, err = ctx.WrapLegacy("crc32", "00000000")
+	if err != ErrWrapKind {
+		t.Errorf("got %v want %v", err, ErrWrapKind)
+	}
+}
+
+func TestWrapLegacyLimits(t *testing.T) {
+	bc, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext().SetMemory(1 << 10)
+	s, err := ctx.WrapLegacy(WrapBcrypt, strings.Replace(string(bc), "$04$", "$31$", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := NewContext().SetVerifyLimits(1<<16, 3, 4).VerifyEncoded(s, []byte("password"))
+	if ok || err != ErrVerifyLimit {
+		t.Errorf("got %v, %v  want %v", ok, err, ErrVerifyLimit)
+	}
+}